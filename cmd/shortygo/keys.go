@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/william1nguyen/shortygo/internal/service"
+)
+
+const keysUsage = `usage: shortygo keys <command> [flags]
+
+commands:
+  create -name NAME -owner OWNER -scopes SCOPE[,SCOPE...] [-rate-limit RPS] [-daily-quota N] [-query-params QUERY]
+  list
+  rotate [-grace DURATION] KEY_ID
+  revoke KEY_ID`
+
+func runKeysCommand(keys *service.APIKeyService, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(keysUsage)
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "create":
+		return createKey(ctx, keys, args[1:])
+	case "list":
+		return listKeys(ctx, keys)
+	case "rotate":
+		return rotateKey(ctx, keys, args[1:])
+	case "revoke":
+		return revokeKey(ctx, keys, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], keysUsage)
+	}
+}
+
+func createKey(ctx context.Context, keys *service.APIKeyService, args []string) error {
+	flags := flag.NewFlagSet("keys create", flag.ContinueOnError)
+	name := flags.String("name", "", "key name")
+	owner := flags.String("owner", "", "key owner")
+	scopes := flags.String("scopes", "", "comma separated scopes: "+strings.Join(service.Scopes, ", "))
+	rateLimit := flags.Float64("rate-limit", 0, "requests per second, replacing the route limits")
+	dailyQuota := flags.Int("daily-quota", 0, "requests per UTC day")
+	queryParams := flags.String("query-params", "", "default query parameters of new links, e.g. utm_source=partner&utm_medium=api")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *name == "" || *owner == "" || *scopes == "" {
+		return fmt.Errorf("-name, -owner and -scopes are required")
+	}
+	if *rateLimit < 0 || *dailyQuota < 0 {
+		return fmt.Errorf("-rate-limit and -daily-quota must not be negative")
+	}
+
+	query, err := url.ParseQuery(*queryParams)
+	if err != nil {
+		return fmt.Errorf("invalid -query-params: %w", err)
+	}
+
+	defaults := make(map[string]string, len(query))
+	for name := range query {
+		defaults[name] = query.Get(name)
+	}
+
+	key, err := keys.CreateKey(ctx, &service.CreateAPIKeyRequest{
+		Name:       *name,
+		Owner:      *owner,
+		Scopes:     strings.Split(*scopes, ","),
+		RateLimit:  *rateLimit,
+		DailyQuota: *dailyQuota,
+
+		DefaultQueryParams: defaults,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created key %s (%s)\n", key.ID, key.Name)
+	fmt.Printf("Secret: %s\n", key.Secret)
+	printSigningSecret(key.SigningSecret)
+	fmt.Println("Store the secret now, it cannot be shown again.")
+	return nil
+}
+
+func listKeys(ctx context.Context, keys *service.APIKeyService) error {
+	list, err := keys.ListKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tOWNER\tSCOPES\tRATE LIMIT\tDAILY QUOTA\tCREATED\tLAST USED\tSTATUS")
+	for _, key := range list {
+		status := "active"
+		if key.IsRevoked() {
+			status = "revoked " + formatTime(key.RevokedAt)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			key.ID, key.Name, key.Owner, strings.Join(key.Scopes, ","),
+			formatLimit(key.RateLimit, "/s"), formatLimit(float64(key.DailyQuota), ""),
+			formatTime(key.CreatedAt), formatTime(key.LastUsedAt), status)
+	}
+	return w.Flush()
+}
+
+func rotateKey(ctx context.Context, keys *service.APIKeyService, args []string) error {
+	flags := flag.NewFlagSet("keys rotate", flag.ContinueOnError)
+	grace := flags.Duration("grace", service.DefaultRotationGracePeriod, "how long the old secret keeps working")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: shortygo keys rotate [-grace DURATION] KEY_ID")
+	}
+
+	key, err := keys.RotateKey(ctx, flags.Arg(0), *grace)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Rotated key %s (%s)\n", key.ID, key.Name)
+	fmt.Printf("Secret: %s\n", key.Secret)
+	printSigningSecret(key.SigningSecret)
+	fmt.Printf("The old secret works until %s.\n", formatTime(key.PreviousExpiresAt))
+	return nil
+}
+
+func revokeKey(ctx context.Context, keys *service.APIKeyService, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: shortygo keys revoke KEY_ID")
+	}
+
+	key, err := keys.RevokeKey(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Revoked key %s (%s)\n", key.ID, key.Name)
+	return nil
+}
+
+func printSigningSecret(signingSecret string) {
+	if signingSecret != "" {
+		fmt.Printf("Signing secret: %s\n", signingSecret)
+	}
+}
+
+func formatLimit(limit float64, unit string) string {
+	if limit == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(limit, 'f', -1, 64) + unit
+}
+
+func formatTime(ts int64) string {
+	if ts == 0 {
+		return "-"
+	}
+	return time.Unix(ts, 0).UTC().Format(time.RFC3339)
+}
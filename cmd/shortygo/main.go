@@ -4,14 +4,26 @@
 // @securityDefinitions.apikey ApiKeyAuth
 // @in header
 // @name x-api-key
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT from the identity provider, as "Bearer <token>"
 
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/pires/go-proxyproto"
 	_ "github.com/william1nguyen/shortygo/docs"
 	"github.com/william1nguyen/shortygo/internal/cache"
 	"github.com/william1nguyen/shortygo/internal/config"
@@ -38,25 +50,140 @@ func setupRedis(cfg *config.Config) *cache.RedisCache {
 	return redisCache
 }
 
-func setupRouter(urlHandler *handler.URLHandler) *gin.Engine {
+func setupDestinationPolicy(cfg *config.Config) *service.DestinationPolicy {
+	policy, err := service.NewDestinationPolicy(cfg.Policy, net.DefaultResolver)
+	if err != nil {
+		log.Fatalf("failed to initialize destination policy: %v", err)
+	}
+
+	go policy.Watch(context.Background(), cfg.Policy.ThreatListReload)
+	return policy
+}
+
+func setupTokenVerifier(cfg *config.Config) *service.TokenVerifier {
+	if cfg.Auth.JWKS == "" {
+		return nil
+	}
+
+	verifier, err := service.NewTokenVerifier(context.Background(), cfg.Auth)
+	if err != nil {
+		log.Fatalf("failed to initialize bearer token authentication: %v", err)
+	}
+	return verifier
+}
+
+func setupRateLimiter(cfg *config.Config, limits *service.RateLimitService) middleware.Limiter {
+	switch cfg.RateLimit.Backend {
+	case "redis":
+		return middleware.NewRedisLimiter(limits)
+	case "local":
+		return middleware.NewLocalLimiter()
+	default:
+		log.Fatalf("unknown RATE_LIMIT_BACKEND %q, expected local or redis", cfg.RateLimit.Backend)
+		return nil
+	}
+}
+
+// rateLimits are the rate limit policies of each route group.
+type rateLimits struct {
+	limiter middleware.Limiter
+	quotas  *service.RateLimitService
+	public  service.RateLimitPolicy
+	api     service.RateLimitPolicy
+	// apiRoutes replace the api policy on specific routes.
+	apiRoutes map[string]service.RateLimitPolicy
+}
+
+func newRateLimits(cfg *config.Config, limits *service.RateLimitService) *rateLimits {
+	return &rateLimits{
+		limiter: setupRateLimiter(cfg, limits),
+		quotas:  limits,
+		public:  service.NewRateLimitPolicy("public", cfg.RateLimit.Public),
+		api:     service.NewRateLimitPolicy("api", cfg.RateLimit.API),
+		apiRoutes: map[string]service.RateLimitPolicy{
+			"/api/v1/shorten": service.NewRateLimitPolicy("shorten", cfg.RateLimit.Shorten),
+		},
+	}
+}
+
+// setupClientIP makes c.ClientIP, which rate limiting, lockouts and logs
+// rely on, only trust client IP headers sent by our own proxies.
+func setupClientIP(router *gin.Engine, cfg *config.Config) {
+	proxies := cfg.Server.TrustedProxies
+	if cfg.Server.ClientIPHeader == config.ProxyProtocol {
+		// The connection address already is the client's.
+		proxies = nil
+	}
+
+	if err := router.SetTrustedProxies(proxies); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
+	router.RemoteIPHeaders = []string{cfg.Server.ClientIPHeader}
+}
+
+// serve runs the server, reading client addresses from PROXY protocol
+// headers sent by trusted proxies when configured to.
+func serve(router *gin.Engine, cfg *config.Config) error {
+	if cfg.Server.ClientIPHeader != config.ProxyProtocol {
+		return router.Run()
+	}
+
+	policy, err := proxyproto.LaxWhiteListPolicy(cfg.Server.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+
+	port := cfg.Server.Port
+	if port == "" {
+		port = "8080"
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(cfg.Server.Host, port))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Listening and serving HTTP with PROXY protocol on %s", listener.Addr())
+	return http.Serve(&proxyproto.Listener{
+		Listener:          listener,
+		Policy:            policy,
+		ReadHeaderTimeout: 10 * time.Second,
+	}, router)
+}
+
+func setupRouter(cfg *config.Config, urlHandler *handler.URLHandler, apiKeys *service.APIKeyService, tokens *service.TokenVerifier, limits *rateLimits) *gin.Engine {
 	router := gin.New()
+	setupClientIP(router, cfg)
 
 	router.Use(gin.Logger(), gin.Recovery())
 	router.Use(corsMiddleware())
-	router.Use(middleware.RateLimiter(100))
 
-	router.GET("/health", handler.CheckHealth)
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	public := router.Group("", middleware.RateLimit(limits.limiter, limits.public, nil))
+	public.GET("/health", handler.CheckHealth)
+	public.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	setupRoutes(router, urlHandler)
+	setupRoutes(cfg, router, public, urlHandler, apiKeys, tokens, limits)
 	return router
 }
 
+// corsAllowedHeaders are the request headers browser clients may send,
+// including those of every API authentication scheme.
+var corsAllowedHeaders = []string{
+	"Content-Type",
+	"Authorization",
+	"X-API-Key",
+	middleware.SignatureHeader,
+	middleware.SignatureKeyIDHeader,
+	middleware.SignatureTimestampHeader,
+	middleware.SignatureNonceHeader,
+	middleware.ContentSHA256Header,
+}
+
 func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", strings.Join(corsAllowedHeaders, ", "))
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -67,15 +194,40 @@ func corsMiddleware() gin.HandlerFunc {
 	}
 }
 
-func setupRoutes(router *gin.Engine, urlHandler *handler.URLHandler) {
+func setupRoutes(cfg *config.Config, router *gin.Engine, public *gin.RouterGroup, urlHandler *handler.URLHandler, apiKeys *service.APIKeyService, tokens *service.TokenVerifier, limits *rateLimits) {
 	api := router.Group("/api/v1")
-	api.Use(middleware.APIKeyAuth())
+	api.Use(middleware.AuthLockout(apiKeys))
+	if tokens != nil {
+		api.Use(middleware.JWTAuth(tokens))
+	}
+	if cfg.Auth.SigningSecret != "" {
+		api.Use(middleware.SignatureAuth(apiKeys))
+	}
+	api.Use(middleware.APIKeyAuth(apiKeys))
+	api.Use(middleware.RateLimit(limits.limiter, limits.api, limits.apiRoutes), middleware.DailyQuota(limits.quotas))
+	{
+		api.POST("/shorten", middleware.RequireScope(service.ScopeLinksWrite), urlHandler.ShortenURL)
+		api.GET("/metrics", middleware.RequireScope(service.ScopeAdmin), urlHandler.GetMetrics)
+		api.DELETE("/links/:shortId", middleware.RequireScope(service.ScopeLinksWrite), urlHandler.DeleteURL)
+		api.GET("/links/:shortId/stats", middleware.RequireScope(service.ScopeStatsRead), urlHandler.GetStats)
+		api.GET("/links/:shortId/qr", middleware.RequireScope(service.ScopeLinksRead), urlHandler.GetQRCode)
+		api.GET("/links/:shortId/rules", middleware.RequireScope(service.ScopeLinksRead), urlHandler.GetRules)
+		api.PUT("/links/:shortId/rules", middleware.RequireScope(service.ScopeLinksWrite), urlHandler.UpdateRules)
+	}
+
+	keyHandler := handler.NewAPIKeyHandler(apiKeys)
+	keys := api.Group("/keys", middleware.RequireScope(service.ScopeAdmin))
 	{
-		api.POST("/shorten", urlHandler.ShortenURL)
-		api.GET("/metrics", urlHandler.GetMetrics)
+		keys.POST("", keyHandler.CreateKey)
+		keys.GET("", keyHandler.ListKeys)
+		keys.POST("/:keyId/rotate", keyHandler.RotateKey)
+		keys.DELETE("/:keyId", keyHandler.RevokeKey)
 	}
 
-	router.GET("/:shortId", urlHandler.RedirectURL)
+	public.GET("/:shortId", urlHandler.RedirectURL)
+	public.GET("/:shortId/*rest", urlHandler.RedirectURL)
+	public.POST("/:shortId", urlHandler.UnlockURL)
+	public.POST("/:shortId/*rest", urlHandler.UnlockURL)
 }
 
 func main() {
@@ -85,9 +237,24 @@ func main() {
 	redisCache := setupRedis(cfg)
 	defer redisCache.Close()
 
-	urlService := service.NewURLService(redisCache)
-	urlHandler := handler.NewURLHandler(urlService)
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		if err := runKeysCommand(service.NewAPIKeyService(cfg.Auth, redisCache), os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	policy := setupDestinationPolicy(cfg)
+	urlService := service.NewURLService(cfg, redisCache, policy)
+	urlHandler := handler.NewURLHandler(cfg, urlService)
+	apiKeys := service.NewAPIKeyService(cfg.Auth, redisCache)
 
-	router := setupRouter(urlHandler)
-	router.Run()
+	tokens := setupTokenVerifier(cfg)
+
+	limits := newRateLimits(cfg, service.NewRateLimitService(redisCache))
+
+	router := setupRouter(cfg, urlHandler, apiKeys, tokens, limits)
+	if err := serve(router, cfg); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -15,32 +15,137 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/v1/metrics": {
+        "/api/v1/keys": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
                     }
                 ],
-                "description": "Returns cache statistics including hit ratio and total requests",
+                "description": "List all API keys, including revoked ones, without their secrets",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Metrics"
+                    "API keys"
                 ],
-                "summary": "Get cache metrics",
-                "responses": {}
+                "summary": "List API keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/service.APIKey"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Issue a new API key. The secret is only returned once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "API keys"
+                ],
+                "summary": "Create API key",
+                "parameters": [
+                    {
+                        "description": "Key name, owner and scopes",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.CreateAPIKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/service.CreateAPIKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
             }
         },
-        "/api/v1/shorten": {
+        "/api/v1/keys/{keyId}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revoke an API key; it stops working immediately",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "API keys"
+                ],
+                "summary": "Revoke API key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API key ID",
+                        "name": "keyId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.APIKey"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/keys/{keyId}/rotate": {
             "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
                     }
                 ],
-                "description": "Shorten long URL",
+                "description": "Issue a new secret for an API key. The old secret keeps working for the grace period (default one day, 0 to revoke it at once).",
                 "consumes": [
                     "application/json"
                 ],
@@ -48,17 +153,23 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "URL"
+                    "API keys"
                 ],
-                "summary": "Shorten URL",
+                "summary": "Rotate API key",
                 "parameters": [
                     {
-                        "description": "Request body for creating short URL",
+                        "type": "string",
+                        "description": "API key ID",
+                        "name": "keyId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Grace period",
                         "name": "request",
                         "in": "body",
-                        "required": true,
                         "schema": {
-                            "$ref": "#/definitions/service.ShortenRequest"
+                            "$ref": "#/definitions/service.RotateAPIKeyRequest"
                         }
                     }
                 ],
@@ -66,7 +177,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/service.ShortenResponse"
+                            "$ref": "#/definitions/service.RotateAPIKeyResponse"
                         }
                     },
                     "400": {
@@ -74,22 +185,34 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "/{shortId}": {
-            "get": {
+        "/api/v1/links/{shortId}": {
+            "delete": {
                 "security": [
                     {
                         "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
                     }
                 ],
-                "description": "Redirect to the original URL using short ID",
+                "description": "Delete a short URL; later visits answer 410 Gone",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
                     "URL"
                 ],
-                "summary": "Redirect URL",
+                "summary": "Delete short URL",
                 "parameters": [
                     {
                         "type": "string",
@@ -100,73 +223,1189 @@ const docTemplate = `{
                     }
                 ],
                 "responses": {
-                    "301": {
-                        "description": "Redirected to original URL",
+                    "204": {
+                        "description": "Deleted"
+                    },
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     },
-                    "400": {
-                        "description": "Bad request or not found",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "handler.ErrorResponse": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "type": "string"
-                },
-                "timestamp": {
-                    "type": "integer"
-                }
-            }
         },
-        "service.ShortenRequest": {
-            "type": "object",
-            "required": [
-                "url"
-            ],
-            "properties": {
-                "ttl": {
-                    "type": "integer"
-                },
-                "url": {
-                    "type": "string"
+        "/api/v1/links/{shortId}/qr": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns a PNG or SVG QR code of the full short URL",
+                "produces": [
+                    "image/png",
+                    "image/svg+xml"
+                ],
+                "tags": [
+                    "Links"
+                ],
+                "summary": "Get QR code",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL ID",
+                        "name": "shortId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "png",
+                            "svg"
+                        ],
+                        "type": "string",
+                        "default": "png",
+                        "description": "Image format",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 2048,
+                        "minimum": 64,
+                        "type": "integer",
+                        "default": 256,
+                        "description": "Width and height in pixels",
+                        "name": "size",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "L",
+                            "M",
+                            "Q",
+                            "H"
+                        ],
+                        "type": "string",
+                        "default": "M",
+                        "description": "Error correction level",
+                        "name": "level",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 16,
+                        "minimum": 0,
+                        "type": "integer",
+                        "default": 4,
+                        "description": "Quiet zone in modules",
+                        "name": "margin",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "000000",
+                        "description": "Foreground color as hex",
+                        "name": "fg",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "ffffff",
+                        "description": "Background color as hex",
+                        "name": "bg",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
                 }
             }
         },
-        "service.ShortenResponse": {
-            "type": "object",
-            "properties": {
-                "created_at": {
-                    "type": "integer"
-                },
-                "expires_at": {
-                    "type": "integer"
-                },
-                "origin_url": {
-                    "type": "string"
-                },
-                "short_id": {
-                    "type": "string"
-                },
-                "short_url": {
-                    "type": "string"
-                }
-            }
-        }
-    },
-    "securityDefinitions": {
-        "ApiKeyAuth": {
-            "type": "apiKey",
-            "name": "x-api-key",
+        "/api/v1/links/{shortId}/rules": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the conditional redirect rules of a short URL in evaluation order",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Links"
+                ],
+                "summary": "Get redirect rules",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL ID",
+                        "name": "shortId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.RulesResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Replace the conditional redirect rules of a short URL. Visitors matching no rule go to the original URL.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Links"
+                ],
+                "summary": "Replace redirect rules",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL ID",
+                        "name": "shortId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Ordered redirect rules",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.RulesRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.RulesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/links/{shortId}/stats": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns click counts of a short URL, broken down per variant for A/B splits",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Links"
+                ],
+                "summary": "Get link statistics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL ID",
+                        "name": "shortId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.URLStats"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/metrics": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns cache statistics including hit ratio and total requests",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Metrics"
+                ],
+                "summary": "Get cache metrics",
+                "responses": {}
+            }
+        },
+        "/api/v1/shorten": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Shorten long URL",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "URL"
+                ],
+                "summary": "Shorten URL",
+                "parameters": [
+                    {
+                        "description": "Request body for creating short URL",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.ShortenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.ShortenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/{shortId}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Redirect to the original URL using short ID. Browsers get an HTML page on failure, API clients get JSON.\nAppending \"+\" to the short ID or passing preview=1 shows the destination instead of redirecting.\nLinks with forward_path also accept a trailing path, which is appended to the target path.",
+                "produces": [
+                    "application/json",
+                    "text/html"
+                ],
+                "tags": [
+                    "URL"
+                ],
+                "summary": "Redirect URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL ID, optionally suffixed with +",
+                        "name": "shortId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Show the preview page instead of redirecting",
+                        "name": "preview",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Link preview, deep link page for mobile visitors, or social preview for crawlers",
+                        "schema": {
+                            "$ref": "#/definitions/service.LinkPreview"
+                        }
+                    },
+                    "301": {
+                        "description": "Redirected to original URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "302": {
+                        "description": "Redirected to original URL of a link that can change or run out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Link is password protected",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown short ID",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Link expired or deleted",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "508": {
+                        "description": "Short links redirect to each other in a loop",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Check the password of a protected short URL and redirect to the original URL",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/html"
+                ],
+                "tags": [
+                    "URL"
+                ],
+                "summary": "Unlock password-protected URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL ID",
+                        "name": "shortId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Link password",
+                        "name": "password",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "303": {
+                        "description": "Redirected to original URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Wrong password",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown short ID",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many wrong passwords",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/{shortId}/{rest}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Redirect to the original URL using short ID. Browsers get an HTML page on failure, API clients get JSON.\nAppending \"+\" to the short ID or passing preview=1 shows the destination instead of redirecting.\nLinks with forward_path also accept a trailing path, which is appended to the target path.",
+                "produces": [
+                    "application/json",
+                    "text/html"
+                ],
+                "tags": [
+                    "URL"
+                ],
+                "summary": "Redirect URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL ID, optionally suffixed with +",
+                        "name": "shortId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Path appended to the target of links forwarding paths",
+                        "name": "rest",
+                        "in": "path"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Show the preview page instead of redirecting",
+                        "name": "preview",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Link preview, deep link page for mobile visitors, or social preview for crawlers",
+                        "schema": {
+                            "$ref": "#/definitions/service.LinkPreview"
+                        }
+                    },
+                    "301": {
+                        "description": "Redirected to original URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "302": {
+                        "description": "Redirected to original URL of a link that can change or run out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Link is password protected",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown short ID",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Link expired or deleted",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "508": {
+                        "description": "Short links redirect to each other in a loop",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Check the password of a protected short URL and redirect to the original URL",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/html"
+                ],
+                "tags": [
+                    "URL"
+                ],
+                "summary": "Unlock password-protected URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL ID",
+                        "name": "shortId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Link password",
+                        "name": "password",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "303": {
+                        "description": "Redirected to original URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Wrong password",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown short ID",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many wrong passwords",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "handler.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "integer"
+                }
+            }
+        },
+        "service.APIKey": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "integer"
+                },
+                "daily_quota": {
+                    "type": "integer"
+                },
+                "default_query_params": {
+                    "description": "DefaultQueryParams are added to the query parameters of the links\ncreated with this key, on top of the configured defaults.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_used_at": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "rate_limit": {
+                    "description": "RateLimit, in requests per second, replaces the route rate limits\nfor this key when set. DailyQuota caps its requests per UTC day.",
+                    "type": "number"
+                },
+                "revoked_at": {
+                    "type": "integer"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "service.CreateAPIKeyRequest": {
+            "type": "object",
+            "required": [
+                "name",
+                "owner",
+                "scopes"
+            ],
+            "properties": {
+                "daily_quota": {
+                    "type": "integer",
+                    "minimum": 0,
+                    "example": 10000
+                },
+                "default_query_params": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "example": "billing-service"
+                },
+                "owner": {
+                    "type": "string",
+                    "example": "billing-team"
+                },
+                "rate_limit": {
+                    "type": "number",
+                    "minimum": 0,
+                    "example": 10
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "links:write"
+                    ]
+                }
+            }
+        },
+        "service.CreateAPIKeyResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "integer"
+                },
+                "daily_quota": {
+                    "type": "integer"
+                },
+                "default_query_params": {
+                    "description": "DefaultQueryParams are added to the query parameters of the links\ncreated with this key, on top of the configured defaults.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_used_at": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "rate_limit": {
+                    "description": "RateLimit, in requests per second, replaces the route rate limits\nfor this key when set. DailyQuota caps its requests per UTC day.",
+                    "type": "number"
+                },
+                "revoked_at": {
+                    "type": "integer"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "secret": {
+                    "description": "Secret is the key to send in the x-api-key header. It cannot be\nretrieved again.",
+                    "type": "string"
+                },
+                "signing_secret": {
+                    "description": "SigningSecret signs requests in place of sending Secret, see\nSignRequest. It is only issued when request signing is enabled.",
+                    "type": "string"
+                }
+            }
+        },
+        "service.DeepLink": {
+            "type": "object",
+            "required": [
+                "app_url"
+            ],
+            "properties": {
+                "app_url": {
+                    "type": "string",
+                    "example": "myapp://product/42"
+                },
+                "fallback_url": {
+                    "type": "string",
+                    "example": "https://example.com/product/42"
+                }
+            }
+        },
+        "service.Destination": {
+            "type": "object",
+            "required": [
+                "url"
+            ],
+            "properties": {
+                "url": {
+                    "type": "string",
+                    "example": "https://example.com/b"
+                },
+                "weight": {
+                    "type": "integer",
+                    "example": 50
+                }
+            }
+        },
+        "service.LinkPreview": {
+            "type": "object",
+            "properties": {
+                "clicks": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "integer"
+                },
+                "destination": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "integer"
+                },
+                "short_id": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.RedirectRule": {
+            "type": "object",
+            "required": [
+                "target"
+            ],
+            "properties": {
+                "countries": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "US"
+                    ]
+                },
+                "devices": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "mobile"
+                    ]
+                },
+                "languages": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "en"
+                    ]
+                },
+                "os": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "ios"
+                    ]
+                },
+                "query": {
+                    "description": "Query requires each parameter to be present with the given value,\nor just present when the value is empty.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "target": {
+                    "type": "string"
+                },
+                "time_from": {
+                    "description": "TimeFrom and TimeTo bound the UTC time of day as \"HH:MM\"; a range\nmay wrap past midnight.",
+                    "type": "string",
+                    "example": "09:00"
+                },
+                "time_to": {
+                    "type": "string",
+                    "example": "17:00"
+                }
+            }
+        },
+        "service.RotateAPIKeyRequest": {
+            "type": "object",
+            "properties": {
+                "grace_period": {
+                    "description": "GracePeriod is how many seconds the old secret keeps working,\nDefaultRotationGracePeriod when unset. Zero cuts it off at once.",
+                    "type": "integer",
+                    "example": 86400
+                }
+            }
+        },
+        "service.RotateAPIKeyResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "integer"
+                },
+                "daily_quota": {
+                    "type": "integer"
+                },
+                "default_query_params": {
+                    "description": "DefaultQueryParams are added to the query parameters of the links\ncreated with this key, on top of the configured defaults.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_used_at": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "previous_expires_at": {
+                    "description": "PreviousExpiresAt is when the old secret stops working.",
+                    "type": "integer"
+                },
+                "rate_limit": {
+                    "description": "RateLimit, in requests per second, replaces the route rate limits\nfor this key when set. DailyQuota caps its requests per UTC day.",
+                    "type": "number"
+                },
+                "revoked_at": {
+                    "type": "integer"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "signing_secret": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.RulesRequest": {
+            "type": "object",
+            "properties": {
+                "rules": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.RedirectRule"
+                    }
+                }
+            }
+        },
+        "service.RulesResponse": {
+            "type": "object",
+            "properties": {
+                "rules": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.RedirectRule"
+                    }
+                },
+                "short_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.ShortenRequest": {
+            "type": "object",
+            "required": [
+                "url"
+            ],
+            "properties": {
+                "activate_at": {
+                    "description": "ActivateAt and ExpiresAt are unix timestamps bounding when the link\nredirects. ExpiresAt replaces TTL; without it, TTL counts from ActivateAt.",
+                    "type": "integer"
+                },
+                "deep_link": {
+                    "description": "DeepLink offers mobile visitors an app before the web target.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/service.DeepLink"
+                        }
+                    ]
+                },
+                "description": {
+                    "type": "string",
+                    "example": "Everything 30% off this week"
+                },
+                "destinations": {
+                    "description": "Destinations split visitors no rule matched by weight, in place of URL.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.Destination"
+                    }
+                },
+                "expires_at": {
+                    "type": "integer"
+                },
+                "fallback_url": {
+                    "description": "FallbackURL is where visitors outside the activation window are sent.",
+                    "type": "string"
+                },
+                "forward_path": {
+                    "description": "ForwardPath lets /{shortId}/rest redirect to the target with rest\nappended to its path.",
+                    "type": "boolean"
+                },
+                "forward_query": {
+                    "description": "ForwardQuery passes the query the visitor put on the short URL on to\nthe target.",
+                    "type": "boolean"
+                },
+                "image_url": {
+                    "type": "string",
+                    "example": "https://example.com/sale.png"
+                },
+                "interstitial": {
+                    "description": "Interstitial always shows the preview page before redirecting,\nfor destinations visitors should check first.",
+                    "type": "boolean"
+                },
+                "max_clicks": {
+                    "description": "MaxClicks makes the link stop working after that many redirects.",
+                    "type": "integer"
+                },
+                "password": {
+                    "description": "Password, when set, must be entered before the link redirects.",
+                    "type": "string"
+                },
+                "query_params": {
+                    "description": "QueryParams are added to the target on redirect, e.g. UTM tags, on\ntop of the configured and API key defaults. Parameters already on the\ntarget win.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "rules": {
+                    "description": "Rules send matching visitors somewhere other than URL.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.RedirectRule"
+                    }
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Fall sale"
+                },
+                "ttl": {
+                    "type": "integer"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.ShortenResponse": {
+            "type": "object",
+            "properties": {
+                "activate_at": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "integer"
+                },
+                "expires_at": {
+                    "type": "integer"
+                },
+                "max_clicks": {
+                    "type": "integer"
+                },
+                "origin_url": {
+                    "type": "string"
+                },
+                "short_id": {
+                    "type": "string"
+                },
+                "short_url": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.URLStats": {
+            "type": "object",
+            "properties": {
+                "clicks": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "integer"
+                },
+                "expires_at": {
+                    "type": "integer"
+                },
+                "origin_url": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "short_id": {
+                    "type": "string"
+                },
+                "variants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.VariantStats"
+                    }
+                }
+            }
+        },
+        "service.VariantStats": {
+            "type": "object",
+            "properties": {
+                "clicks": {
+                    "type": "integer"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "variant": {
+                    "type": "integer"
+                },
+                "weight": {
+                    "type": "integer"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "type": "apiKey",
+            "name": "x-api-key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "JWT from the identity provider, as \"Bearer \u003ctoken\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
             "in": "header"
         }
     }
@@ -2,8 +2,10 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -12,6 +14,8 @@ import (
 	"github.com/william1nguyen/shortygo/pkg/utils"
 )
 
+var ErrKeyNotFound = errors.New("key not found")
+
 type RedisCache struct {
 	clients []*redis.Client
 	metrics *CacheMetrics
@@ -58,10 +62,27 @@ func NewRedisCache(config config.RedisConfig) (*RedisCache, error) {
 }
 
 func (r *RedisCache) getClient(key string) *redis.Client {
-	index := utils.ConsistentHashing(key, len(r.clients))
+	index := utils.ConsistentHashing(shardKey(key), len(r.clients))
 	return r.clients[index]
 }
 
+// shardKey returns the part of key between the first "{" and "}", like a
+// Redis Cluster hash tag, so related keys such as "clicks:{id}" land on the
+// same instance as "id".
+func shardKey(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return key
+	}
+
+	return key[start+1 : start+1+end]
+}
+
 func (r *RedisCache) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
 	atomic.AddInt64(&r.metrics.TotalRequests, 1)
 
@@ -74,6 +95,25 @@ func (r *RedisCache) Set(ctx context.Context, key string, value string, ttl time
 	return nil
 }
 
+// Update overwrites an existing key and keeps its TTL, returning
+// ErrKeyNotFound when the key is gone.
+func (r *RedisCache) Update(ctx context.Context, key string, value string) error {
+	atomic.AddInt64(&r.metrics.TotalRequests, 1)
+
+	client := r.getClient(key)
+	err := client.SetArgs(ctx, key, value, redis.SetArgs{Mode: "XX", KeepTTL: true}).Err()
+
+	if err != nil {
+		atomic.AddInt64(&r.metrics.Errors, 1)
+		if errors.Is(err, redis.Nil) {
+			err = ErrKeyNotFound
+		}
+		return fmt.Errorf("failed to update key %s:%w", key, err)
+	}
+
+	return nil
+}
+
 func (r *RedisCache) Get(ctx context.Context, key string) (string, error) {
 	atomic.AddInt64(&r.metrics.TotalRequests, 1)
 
@@ -82,6 +122,9 @@ func (r *RedisCache) Get(ctx context.Context, key string) (string, error) {
 
 	if err != nil {
 		atomic.AddInt64(&r.metrics.Errors, 1)
+		if errors.Is(err, redis.Nil) {
+			err = ErrKeyNotFound
+		}
 		return "", fmt.Errorf("failed to get key %s:%w", key, err)
 	}
 
@@ -103,6 +146,167 @@ func (r *RedisCache) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+func (r *RedisCache) Incr(ctx context.Context, key string) (int64, error) {
+	atomic.AddInt64(&r.metrics.TotalRequests, 1)
+
+	client := r.getClient(key)
+	value, err := client.Incr(ctx, key).Result()
+
+	if err != nil {
+		atomic.AddInt64(&r.metrics.Errors, 1)
+		return 0, fmt.Errorf("failed to increment key %s:%w", key, err)
+	}
+
+	return value, nil
+}
+
+// incrWithTTLScript increments a counter and starts its TTL unless it
+// already has one, in a single step so the counter cannot be left without
+// an expiry. ARGV: TTL in milliseconds.
+var incrWithTTLScript = redis.NewScript(`
+local value = redis.call("INCR", KEYS[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return value
+`)
+
+// IncrWithTTL increments a counter that expires ttl after its first
+// increment.
+func (r *RedisCache) IncrWithTTL(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	atomic.AddInt64(&r.metrics.TotalRequests, 1)
+
+	client := r.getClient(key)
+	value, err := incrWithTTLScript.Run(ctx, client, []string{key}, ttl.Milliseconds()).Int64()
+
+	if err != nil {
+		atomic.AddInt64(&r.metrics.Errors, 1)
+		return 0, fmt.Errorf("failed to increment key %s:%w", key, err)
+	}
+
+	return value, nil
+}
+
+// HSet writes hash fields and sets the TTL of the hash.
+func (r *RedisCache) HSet(ctx context.Context, key string, values map[string]interface{}, ttl time.Duration) error {
+	atomic.AddInt64(&r.metrics.TotalRequests, 1)
+
+	client := r.getClient(key)
+	_, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, values)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+
+	if err != nil {
+		atomic.AddInt64(&r.metrics.Errors, 1)
+		return fmt.Errorf("failed to set hash %s:%w", key, err)
+	}
+
+	return nil
+}
+
+func (r *RedisCache) HIncrBy(ctx context.Context, key string, field string, incr int64) (int64, error) {
+	atomic.AddInt64(&r.metrics.TotalRequests, 1)
+
+	client := r.getClient(key)
+	value, err := client.HIncrBy(ctx, key, field, incr).Result()
+
+	if err != nil {
+		atomic.AddInt64(&r.metrics.Errors, 1)
+		return 0, fmt.Errorf("failed to increment hash field %s %s:%w", key, field, err)
+	}
+
+	return value, nil
+}
+
+func (r *RedisCache) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	atomic.AddInt64(&r.metrics.TotalRequests, 1)
+
+	client := r.getClient(key)
+	value, err := client.HGetAll(ctx, key).Result()
+
+	if err != nil {
+		atomic.AddInt64(&r.metrics.Errors, 1)
+		return nil, fmt.Errorf("failed to get hash %s:%w", key, err)
+	}
+
+	atomic.AddInt64(&r.metrics.Hits, 1)
+	return value, nil
+}
+
+func (r *RedisCache) SAdd(ctx context.Context, key string, members ...interface{}) error {
+	atomic.AddInt64(&r.metrics.TotalRequests, 1)
+
+	client := r.getClient(key)
+	if err := client.SAdd(ctx, key, members...).Err(); err != nil {
+		atomic.AddInt64(&r.metrics.Errors, 1)
+		return fmt.Errorf("failed to add to set %s:%w", key, err)
+	}
+
+	return nil
+}
+
+func (r *RedisCache) SMembers(ctx context.Context, key string) ([]string, error) {
+	atomic.AddInt64(&r.metrics.TotalRequests, 1)
+
+	client := r.getClient(key)
+	members, err := client.SMembers(ctx, key).Result()
+
+	if err != nil {
+		atomic.AddInt64(&r.metrics.Errors, 1)
+		return nil, fmt.Errorf("failed to get set %s:%w", key, err)
+	}
+
+	atomic.AddInt64(&r.metrics.Hits, 1)
+	return members, nil
+}
+
+func (r *RedisCache) SCard(ctx context.Context, key string) (int64, error) {
+	atomic.AddInt64(&r.metrics.TotalRequests, 1)
+
+	client := r.getClient(key)
+	count, err := client.SCard(ctx, key).Result()
+
+	if err != nil {
+		atomic.AddInt64(&r.metrics.Errors, 1)
+		return 0, fmt.Errorf("failed to count set %s:%w", key, err)
+	}
+
+	return count, nil
+}
+
+// RunScript runs a Lua script on the instance owning keys[0]; all keys must
+// share its hash tag.
+func (r *RedisCache) RunScript(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	atomic.AddInt64(&r.metrics.TotalRequests, 1)
+
+	client := r.getClient(keys[0])
+	value, err := script.Run(ctx, client, keys, args...).Result()
+
+	if err != nil {
+		atomic.AddInt64(&r.metrics.Errors, 1)
+		return nil, fmt.Errorf("failed to run script on key %s:%w", keys[0], err)
+	}
+
+	return value, nil
+}
+
+// SetNX sets key only if it does not exist yet and reports whether it did.
+func (r *RedisCache) SetNX(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	atomic.AddInt64(&r.metrics.TotalRequests, 1)
+
+	client := r.getClient(key)
+	set, err := client.SetNX(ctx, key, value, ttl).Result()
+
+	if err != nil {
+		atomic.AddInt64(&r.metrics.Errors, 1)
+		return false, fmt.Errorf("failed to set key %s:%w", key, err)
+	}
+
+	return set, nil
+}
+
 func (r *RedisCache) Exists(ctx context.Context, key string) (bool, error) {
 	atomic.AddInt64(&r.metrics.TotalRequests, 1)
 
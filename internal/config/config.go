@@ -1,20 +1,38 @@
 package config
 
 import (
+	"log"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
-	Server  ServerConfig
-	Redis   RedisConfig
-	BaseURL string
+	Server    ServerConfig
+	Redis     RedisConfig
+	Links     LinkConfig
+	Auth      AuthConfig
+	RateLimit RateLimitConfig
+	Policy    PolicyConfig
+	BaseURL   string
 }
 
+// ProxyProtocol as the client IP header reads client addresses from the
+// PROXY protocol header of each connection instead of an HTTP header.
+const ProxyProtocol = "PROXY"
+
 type ServerConfig struct {
 	Host string
 	Port string
+	// TrustedProxies are the CIDRs or IPs of the load balancers in front of
+	// us. Client IP headers from anyone else are ignored.
+	TrustedProxies []string
+	// ClientIPHeader is the header trusted proxies put the client IP in,
+	// e.g. X-Forwarded-For or X-Real-IP, or ProxyProtocol.
+	ClientIPHeader string
 }
 
 type RedisConfig struct {
@@ -23,21 +41,176 @@ type RedisConfig struct {
 	DB       int
 }
 
+type LinkConfig struct {
+	// CountryHeader names the header carrying the visitor's ISO country
+	// code, as set by the CDN or load balancer in front of us.
+	CountryHeader string
+	// DefaultQueryParams are added to the query parameters of every new
+	// link, e.g. "utm_source=shortygo&utm_medium=link".
+	DefaultQueryParams url.Values
+	// AppSchemes are the custom URL schemes deep links may open, such as
+	// "myapp".
+	AppSchemes []string
+	// OwnDomains are further domains serving our short links besides the
+	// BASE_URL host.
+	OwnDomains []string
+	// Shorteners are domains of other URL shorteners, treated like our
+	// own domains when links target them.
+	Shorteners []string
+	// SelfLinks is "resolve" to replace targets on our own domains or
+	// other shorteners with their final destination, or "reject".
+	SelfLinks string
+}
+
+type AuthConfig struct {
+	// JWKS is the path or http(s) URL of the JSON Web Key Set bearer
+	// tokens are verified against. Bearer authentication is off when empty.
+	JWKS string
+	// JWKSRefresh is how often the key set is reloaded.
+	JWKSRefresh time.Duration
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer   string
+	Audience string
+	// OwnerClaim names the claim used as the owner of created links.
+	OwnerClaim string
+	// ScopesClaim names the claim holding the granted scopes, either a
+	// space separated string or a list.
+	ScopesClaim string
+	// SignatureMaxSkew is how far the timestamp of a signed request may be
+	// from our clock.
+	SignatureMaxSkew time.Duration
+	// SigningSecret derives the request signing secrets handed out with
+	// API keys. Signed requests are disabled without it.
+	SigningSecret string
+	// MaxFailures failed authentications from one IP block that IP for
+	// Lockout.
+	MaxFailures int
+	Lockout     time.Duration
+}
+
+type RateLimitConfig struct {
+	// Backend is "local" to limit each instance on its own or "redis" to
+	// share limits across instances.
+	Backend string
+	// Public, API and Shorten are the requests per second allowed to each
+	// client on redirects and other public routes, on the API, and on
+	// creating links.
+	Public  float64
+	API     float64
+	Shorten float64
+	// MaxEntries caps how many clients are tracked at once; the least
+	// recently seen client is forgotten first.
+	MaxEntries int
+	// IdleTTL is how long a client is tracked after its last request.
+	IdleTTL time.Duration
+}
+
+// PolicyConfig restricts where links may point to.
+type PolicyConfig struct {
+	// BlockedDomains and AllowedDomains match a domain and all of its
+	// subdomains. When AllowedDomains is set, no other domain is allowed.
+	BlockedDomains []string
+	AllowedDomains []string
+	// BlockedPatterns are regular expressions matched against the whole
+	// destination URL.
+	BlockedPatterns []string
+	// ThreatLists are paths of hosts-format blocklists or URLhaus CSV
+	// dumps (*.csv), reloaded every ThreatListReload when they change.
+	ThreatLists      []string
+	ThreatListReload time.Duration
+	// BlockPrivate rejects destinations on loopback, private, link-local
+	// and other non-public addresses, whether written as IPs or resolved
+	// from host names. PrivateAllowlist holds the CIDRs, IPs and domains
+	// still allowed, for internal deployments.
+	BlockPrivate     bool
+	PrivateAllowlist []string
+}
+
 func Load() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Host: os.Getenv("HOST"),
-			Port: os.Getenv("PORT"),
+			Host:           os.Getenv("HOST"),
+			Port:           os.Getenv("PORT"),
+			TrustedProxies: withoutEmpty(parseList(os.Getenv("TRUSTED_PROXIES"))),
+			ClientIPHeader: getEnv("CLIENT_IP_HEADER", "X-Forwarded-For"),
 		},
 		Redis: RedisConfig{
 			Addrs:    parseList(os.Getenv("REDIS_ADDRS")),
 			Password: os.Getenv("REDIS_PASSWORD"),
 			DB:       coerceInt(os.Getenv("REDIS_DB")),
 		},
+		Links: LinkConfig{
+			CountryHeader:      getEnv("COUNTRY_HEADER", "CF-IPCountry"),
+			DefaultQueryParams: parseQuery(os.Getenv("DEFAULT_QUERY_PARAMS")),
+			AppSchemes:         parseList(strings.ToLower(os.Getenv("ALLOWED_APP_SCHEMES"))),
+			OwnDomains:         withoutEmpty(parseList(strings.ToLower(os.Getenv("OWN_DOMAINS")))),
+			Shorteners:         withoutEmpty(parseList(strings.ToLower(os.Getenv("KNOWN_SHORTENERS")))),
+			SelfLinks:          getEnv("SELF_LINKS", "resolve"),
+		},
+		Auth: AuthConfig{
+			JWKS:        os.Getenv("JWT_JWKS"),
+			JWKSRefresh: parseDuration(os.Getenv("JWT_JWKS_REFRESH"), time.Hour),
+			Issuer:      os.Getenv("JWT_ISSUER"),
+			Audience:    os.Getenv("JWT_AUDIENCE"),
+			OwnerClaim:  getEnv("JWT_OWNER_CLAIM", "sub"),
+			ScopesClaim: getEnv("JWT_SCOPES_CLAIM", "scope"),
+
+			SignatureMaxSkew: parseDuration(os.Getenv("SIGNATURE_MAX_SKEW"), 5*time.Minute),
+			SigningSecret:    os.Getenv("SIGNATURE_SECRET"),
+			MaxFailures:      getEnvInt("AUTH_MAX_FAILURES", 10),
+			Lockout:          parseDuration(os.Getenv("AUTH_LOCKOUT"), 15*time.Minute),
+		},
+		RateLimit: RateLimitConfig{
+			Backend:    getEnv("RATE_LIMIT_BACKEND", "local"),
+			Public:     getEnvFloat("RATE_LIMIT_PUBLIC", 100),
+			API:        getEnvFloat("RATE_LIMIT_API", 20),
+			Shorten:    getEnvFloat("RATE_LIMIT_SHORTEN", 2),
+			MaxEntries: getEnvInt("RATE_LIMIT_MAX_ENTRIES", 100000),
+			IdleTTL:    parseDuration(os.Getenv("RATE_LIMIT_IDLE_TTL"), 10*time.Minute),
+		},
+		Policy: PolicyConfig{
+			BlockedDomains:   withoutEmpty(parseList(strings.ToLower(os.Getenv("BLOCKED_DOMAINS")))),
+			AllowedDomains:   withoutEmpty(parseList(strings.ToLower(os.Getenv("ALLOWED_DOMAINS")))),
+			BlockedPatterns:  strings.Fields(os.Getenv("BLOCKED_URL_PATTERNS")),
+			ThreatLists:      withoutEmpty(parseList(os.Getenv("THREAT_LISTS"))),
+			ThreatListReload: parseDuration(os.Getenv("THREAT_LIST_RELOAD"), 5*time.Minute),
+			BlockPrivate:     getEnvBool("BLOCK_PRIVATE_DESTINATIONS", false),
+			PrivateAllowlist: withoutEmpty(parseList(strings.ToLower(os.Getenv("PRIVATE_DESTINATIONS_ALLOWLIST")))),
+		},
 		BaseURL: os.Getenv("BASE_URL"),
 	}
 }
 
+func getEnv(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value := coerceInt(os.Getenv(key)); value > 0 {
+		return value
+	}
+	return fallback
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
 func coerceInt(s string) int {
 	if s == "" {
 		return 0
@@ -50,6 +223,19 @@ func coerceInt(s string) int {
 	return value
 }
 
+func parseDuration(s string, fallback time.Duration) time.Duration {
+	if s == "" {
+		return fallback
+	}
+	value, err := time.ParseDuration(s)
+	if err != nil || value <= 0 {
+		log.Printf("Ignoring invalid duration %q", s)
+		return fallback
+	}
+
+	return value
+}
+
 func parseList(s string) []string {
 	lst := strings.Split(s, ",")
 	for i := range lst {
@@ -57,3 +243,16 @@ func parseList(s string) []string {
 	}
 	return lst
 }
+
+func withoutEmpty(lst []string) []string {
+	return slices.DeleteFunc(lst, func(s string) bool { return s == "" })
+}
+
+func parseQuery(s string) url.Values {
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		log.Printf("Ignoring malformed query %q: %v", s, err)
+		return nil
+	}
+	return values
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/william1nguyen/shortygo/internal/service"
+)
+
+type APIKeyHandler struct {
+	service *service.APIKeyService
+}
+
+func NewAPIKeyHandler(service *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{service: service}
+}
+
+// CreateKey godoc
+// @Summary      Create API key
+// @Description  Issue a new API key. The secret is only returned once.
+// @Tags         API keys
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      service.CreateAPIKeyRequest  true  "Key name, owner and scopes"
+// @Success      201      {object}  service.CreateAPIKeyResponse
+// @Failure      400      {object}  ErrorResponse
+// @Router       /api/v1/keys [post]
+func (h *APIKeyHandler) CreateKey(c *gin.Context) {
+	var req service.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "Invalid request body",
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	response, err := h.service.CreateKey(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListKeys godoc
+// @Summary      List API keys
+// @Description  List all API keys, including revoked ones, without their secrets
+// @Tags         API keys
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200      {array}   service.APIKey
+// @Router       /api/v1/keys [get]
+func (h *APIKeyHandler) ListKeys(c *gin.Context) {
+	keys, err := h.service.ListKeys(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:     "Failed to list API keys",
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// RotateKey godoc
+// @Summary      Rotate API key
+// @Description  Issue a new secret for an API key. The old secret keeps working for the grace period (default one day, 0 to revoke it at once).
+// @Tags         API keys
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        keyId    path      string                       true   "API key ID"
+// @Param        request  body      service.RotateAPIKeyRequest  false  "Grace period"
+// @Success      200      {object}  service.RotateAPIKeyResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Router       /api/v1/keys/{keyId}/rotate [post]
+func (h *APIKeyHandler) RotateKey(c *gin.Context) {
+	var req service.RotateAPIKeyRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:     "Invalid request body",
+				Timestamp: time.Now().Unix(),
+			})
+			return
+		}
+	}
+
+	gracePeriod := service.DefaultRotationGracePeriod
+	if req.GracePeriod != nil {
+		gracePeriod = time.Duration(*req.GracePeriod) * time.Second
+	}
+
+	response, err := h.service.RotateKey(c.Request.Context(), c.Param("keyId"), gracePeriod)
+	if err != nil {
+		respondKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeKey godoc
+// @Summary      Revoke API key
+// @Description  Revoke an API key; it stops working immediately
+// @Tags         API keys
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Produce      json
+// @Param        keyId    path      string  true  "API key ID"
+// @Success      200      {object}  service.APIKey
+// @Failure      404      {object}  ErrorResponse
+// @Router       /api/v1/keys/{keyId} [delete]
+func (h *APIKeyHandler) RevokeKey(c *gin.Context) {
+	key, err := h.service.RevokeKey(c.Request.Context(), c.Param("keyId"))
+	if err != nil {
+		respondKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
+
+func respondKeyError(c *gin.Context, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, service.ErrAPIKeyNotFound) {
+		status = http.StatusNotFound
+	}
+
+	c.JSON(status, ErrorResponse{
+		Error:     err.Error(),
+		Timestamp: time.Now().Unix(),
+	})
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/william1nguyen/shortygo/internal/service"
+)
+
+// GetStats godoc
+// @Summary      Get link statistics
+// @Description  Returns click counts of a short URL, broken down per variant for A/B splits
+// @Tags         Links
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Produce      json
+// @Param        shortId  path      string  true  "Short URL ID"
+// @Success      200      {object}  service.URLStats
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      410      {object}  ErrorResponse
+// @Router       /api/v1/links/{shortId}/stats [get]
+func (h *URLHandler) GetStats(c *gin.Context) {
+	stats, err := h.service.GetStats(c.Request.Context(), c.Param("shortId"), principal(c))
+	if err != nil {
+		respondLookupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetQRCode godoc
+// @Summary      Get QR code
+// @Description  Returns a PNG or SVG QR code of the full short URL
+// @Tags         Links
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Produce      png,image/svg+xml
+// @Param        shortId  path      string  true   "Short URL ID"
+// @Param        format   query     string  false  "Image format"  Enums(png, svg)  default(png)
+// @Param        size     query     int     false  "Width and height in pixels"  minimum(64)  maximum(2048)  default(256)
+// @Param        level    query     string  false  "Error correction level"  Enums(L, M, Q, H)  default(M)
+// @Param        margin   query     int     false  "Quiet zone in modules"  minimum(0)  maximum(16)  default(4)
+// @Param        fg       query     string  false  "Foreground color as hex"  default(000000)
+// @Param        bg       query     string  false  "Background color as hex"  default(ffffff)
+// @Success      200      {file}    binary
+// @Success      304      "Not modified"
+// @Failure      400      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      410      {object}  ErrorResponse
+// @Router       /api/v1/links/{shortId}/qr [get]
+func (h *URLHandler) GetQRCode(c *gin.Context) {
+	var req service.QRCodeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "Invalid QR code options",
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	link, err := h.service.GetManagedLink(c.Request.Context(), c.Param("shortId"), principal(c))
+	if err != nil {
+		respondLookupError(c, err)
+		return
+	}
+
+	etag := h.service.QRCodeETag(link, &req)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, max-age=86400")
+
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	qr, err := h.service.RenderQRCode(link, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	c.Data(http.StatusOK, qr.ContentType, qr.Data)
+}
+
+// GetRules godoc
+// @Summary      Get redirect rules
+// @Description  List the conditional redirect rules of a short URL in evaluation order
+// @Tags         Links
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Produce      json
+// @Param        shortId  path      string  true  "Short URL ID"
+// @Success      200      {object}  service.RulesResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      410      {object}  ErrorResponse
+// @Router       /api/v1/links/{shortId}/rules [get]
+func (h *URLHandler) GetRules(c *gin.Context) {
+	link, err := h.service.GetManagedLink(c.Request.Context(), c.Param("shortId"), principal(c))
+	if err != nil {
+		respondLookupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, service.RulesResponse{
+		ShortID: link.ShortID,
+		Rules:   link.Rules,
+	})
+}
+
+// UpdateRules godoc
+// @Summary      Replace redirect rules
+// @Description  Replace the conditional redirect rules of a short URL. Visitors matching no rule go to the original URL.
+// @Tags         Links
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        shortId  path      string                true  "Short URL ID"
+// @Param        request  body      service.RulesRequest  true  "Ordered redirect rules"
+// @Success      200      {object}  service.RulesResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      410      {object}  ErrorResponse
+// @Router       /api/v1/links/{shortId}/rules [put]
+func (h *URLHandler) UpdateRules(c *gin.Context) {
+	var req service.RulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "Invalid request body",
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	link, err := h.service.UpdateRules(c.Request.Context(), c.Param("shortId"), req.Rules, principal(c))
+	if err != nil {
+		respondUpdateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, service.RulesResponse{
+		ShortID: link.ShortID,
+		Rules:   link.Rules,
+	})
+}
+
+// respondUpdateError reports lookup failures as such and anything else as
+// a rejected request.
+func respondUpdateError(c *gin.Context, err error) {
+	if isLookupError(err) {
+		respondLookupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, ErrorResponse{
+		Error:     err.Error(),
+		Timestamp: time.Now().Unix(),
+	})
+}
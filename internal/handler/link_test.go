@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/william1nguyen/shortygo/internal/cache"
+	"github.com/william1nguyen/shortygo/internal/config"
+	"github.com/william1nguyen/shortygo/internal/middleware"
+	"github.com/william1nguyen/shortygo/internal/service"
+)
+
+func TestLinkOwnership(t *testing.T) {
+	t.Setenv("X_API_KEY", "")
+
+	server := miniredis.RunT(t)
+	redisCache, err := cache.NewRedisCache(config.RedisConfig{Addrs: []string{server.Addr()}})
+	if err != nil {
+		t.Fatalf("NewRedisCache: %v", err)
+	}
+	t.Cleanup(redisCache.Close)
+
+	cfg := &config.Config{BaseURL: "https://sho.rt"}
+	keys := service.NewAPIKeyService(cfg.Auth, redisCache)
+	h := NewURLHandler(cfg, service.NewURLService(cfg, redisCache, nil))
+
+	newKey := func(owner string, scopes ...string) string {
+		created, err := keys.CreateKey(context.Background(), &service.CreateAPIKeyRequest{
+			Name:   owner,
+			Owner:  owner,
+			Scopes: scopes,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return created.Secret
+	}
+	alice := newKey("alice", service.ScopeLinksWrite, service.ScopeStatsRead)
+	bob := newKey("bob", service.ScopeLinksWrite, service.ScopeStatsRead)
+	admin := newKey("ops", service.ScopeAdmin)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group("/api/v1", middleware.APIKeyAuth(keys))
+	api.POST("/shorten", h.ShortenURL)
+	api.GET("/links/:shortId/stats", h.GetStats)
+	api.DELETE("/links/:shortId", h.DeleteURL)
+
+	serve := func(method string, path string, apiKey string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", apiKey)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodPost, "/api/v1/shorten", alice, `{"url":"https://example.com/a"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("shorten got %d: %s", w.Code, w.Body)
+	}
+	var created service.ShortenResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	stats := "/api/v1/links/" + created.ShortID + "/stats"
+	link := "/api/v1/links/" + created.ShortID
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		apiKey   string
+		wantCode int
+	}{
+		{name: "other owner reads stats", method: http.MethodGet, path: stats, apiKey: bob, wantCode: http.StatusForbidden},
+		{name: "other owner deletes", method: http.MethodDelete, path: link, apiKey: bob, wantCode: http.StatusForbidden},
+		{name: "owner reads stats", method: http.MethodGet, path: stats, apiKey: alice, wantCode: http.StatusOK},
+		{name: "admin reads stats", method: http.MethodGet, path: stats, apiKey: admin, wantCode: http.StatusOK},
+		{name: "admin deletes", method: http.MethodDelete, path: link, apiKey: admin, wantCode: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(tt.method, tt.path, tt.apiKey, ""); w.Code != tt.wantCode {
+				t.Fatalf("got %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+		})
+	}
+}
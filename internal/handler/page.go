@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"embed"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var pages = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+func renderPage(c *gin.Context, status int, name string, data gin.H) {
+	c.Render(status, render.HTML{Template: pages, Name: name, Data: data})
+}
+
+// respondError answers browsers with a branded HTML page and API clients
+// with the JSON ErrorResponse.
+func respondError(c *gin.Context, status int, message string) {
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		renderPage(c, status, "error.html", gin.H{
+			"Title":   http.StatusText(status),
+			"Message": message,
+			"Status":  status,
+		})
+		return
+	}
+
+	c.JSON(status, ErrorResponse{
+		Error:     message,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+func queryFlag(c *gin.Context, name string) bool {
+	value, _ := strconv.ParseBool(c.Query(name))
+	return value
+}
+
+func formatUnix(ts int64) string {
+	if ts == 0 {
+		return "unknown"
+	}
+	return time.Unix(ts, 0).UTC().Format("Jan 2, 2006 15:04 UTC")
+}
@@ -1,14 +1,30 @@
 package handler
 
 import (
+	"errors"
+	"html/template"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/william1nguyen/shortygo/internal/config"
+	"github.com/william1nguyen/shortygo/internal/middleware"
 	"github.com/william1nguyen/shortygo/internal/service"
+	"github.com/william1nguyen/shortygo/pkg/utils"
+)
+
+const (
+	variantCookieMaxAge = 30 * 24 * time.Hour
+
+	// permanentRedirectCacheTTL is the longest browsers may cache a 301.
+	permanentRedirectCacheTTL = time.Hour
 )
 
 type URLHandler struct {
+	cfg     *config.Config
 	service *service.URLService
 }
 
@@ -17,8 +33,8 @@ type ErrorResponse struct {
 	Timestamp int64  `json:"timestamp"`
 }
 
-func NewURLHandler(service *service.URLService) *URLHandler {
-	return &URLHandler{service: service}
+func NewURLHandler(cfg *config.Config, service *service.URLService) *URLHandler {
+	return &URLHandler{cfg: cfg, service: service}
 }
 
 // Shorten godoc
@@ -42,7 +58,7 @@ func (h *URLHandler) ShortenURL(c *gin.Context) {
 		return
 	}
 
-	response, err := h.service.ShortenURL(c.Request.Context(), &req)
+	response, err := h.service.ShortenURL(c.Request.Context(), &req, principal(c))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:     err.Error(),
@@ -56,33 +72,367 @@ func (h *URLHandler) ShortenURL(c *gin.Context) {
 
 // RedirectURL godoc
 // @Summary      Redirect URL
-// @Description  Redirect to the original URL using short ID
+// @Description  Redirect to the original URL using short ID. Browsers get an HTML page on failure, API clients get JSON.
+// @Description  Appending "+" to the short ID or passing preview=1 shows the destination instead of redirecting.
+// @Description  Links with forward_path also accept a trailing path, which is appended to the target path.
 // @Tags         URL
 // @Security     ApiKeyAuth
-// @Param        shortId  path      string  true  "Short URL ID"
+// @Produce      json,html
+// @Param        shortId  path      string  true   "Short URL ID, optionally suffixed with +"
+// @Param        rest     path      string  false  "Path appended to the target of links forwarding paths"
+// @Param        preview  query     bool    false  "Show the preview page instead of redirecting"
+// @Success      200      {object}  service.LinkPreview  "Link preview, deep link page for mobile visitors, or social preview for crawlers"
 // @Success      301      {string}  string  "Redirected to original URL"
-// @Failure      400      {object}  ErrorResponse  "Bad request or not found"
+// @Success      302      {string}  string  "Redirected to original URL of a link that can change or run out"
+// @Failure      401      {object}  ErrorResponse  "Link is password protected"
+// @Failure      404      {object}  ErrorResponse  "Unknown short ID"
+// @Failure      410      {object}  ErrorResponse  "Link expired or deleted"
+// @Failure      508      {object}  ErrorResponse  "Short links redirect to each other in a loop"
 // @Router       /{shortId} [get]
+// @Router       /{shortId}/{rest} [get]
 func (h *URLHandler) RedirectURL(c *gin.Context) {
 	shortID := c.Param("shortId")
+	preview := queryFlag(c, "preview")
+	if strings.HasSuffix(shortID, "+") {
+		shortID = strings.TrimSuffix(shortID, "+")
+		preview = true
+	}
+
 	if shortID == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:     "Short ID required",
-			Timestamp: time.Now().Unix(),
-		})
+		respondError(c, http.StatusBadRequest, "Short ID required")
 		return
 	}
 
-	originalURL, err := h.service.GetOriginalURL(c.Request.Context(), shortID)
+	if hops, _ := strconv.Atoi(c.Query(service.HopsQueryParam)); hops >= service.MaxRedirectHops {
+		respondError(c, http.StatusLoopDetected, "This link redirects in a loop")
+		return
+	}
+
+	link, err := h.service.GetLink(c.Request.Context(), shortID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:     "URL not found",
-			Timestamp: time.Now().Unix(),
-		})
+		respondLookupError(c, err)
+		return
+	}
+
+	if !checkPath(c, link) || !checkWindow(c, link) {
+		return
+	}
+
+	if !link.SocialMeta.IsEmpty() && utils.IsLinkPreviewCrawler(c.Request.UserAgent()) {
+		h.renderSocialPreview(c, link)
+		return
+	}
+
+	if link.IsProtected() {
+		renderPasswordForm(c, http.StatusUnauthorized, shortID, "This link is password protected")
+		return
+	}
+
+	if preview || (link.Interstitial && !queryFlag(c, "continue")) {
+		h.renderPreview(c, link)
+		return
+	}
+
+	h.redirect(c, link, redirectStatus(link))
+}
+
+// renderSocialPreview serves link preview crawlers a page carrying the
+// link's Open Graph and Twitter Card tags. It never reveals the target, so
+// it is safe for protected links too.
+func (h *URLHandler) renderSocialPreview(c *gin.Context, link *service.Link) {
+	title := link.Title
+	if title == "" {
+		title = "Shared link"
+	}
+
+	renderPage(c, http.StatusOK, "social.html", gin.H{
+		"Title": title,
+		"Social": gin.H{
+			"URL":         h.cfg.BaseURL + "/" + link.ShortID,
+			"Title":       link.Title,
+			"Description": link.Description,
+			"ImageURL":    link.ImageURL,
+		},
+	})
+}
+
+// redirect sends the visitor to the target resolved for them and counts
+// the click, unless the visitor is a crawler or bot.
+func (h *URLHandler) redirect(c *gin.Context, link *service.Link, status int) {
+	// Crawlers unfurling a link in a chat must not use up its clicks
+	// before the recipient opens it.
+	automated := isAutomated(c)
+	if automated && link.MaxClicks > 0 {
+		h.renderSocialPreview(c, link)
+		return
+	}
+
+	resolution := h.service.ResolveTarget(link, h.visitorFromRequest(c, link))
+
+	if !automated && !h.recordClick(c, link, resolution.Variant) {
+		return
+	}
+
+	if resolution.Variant != service.NoVariant {
+		c.SetCookie(variantCookie(link.ShortID), strconv.Itoa(resolution.Variant),
+			int(variantCookieMaxAge.Seconds()), "/"+link.ShortID, "", false, true)
+	}
+
+	if resolution.AppURL != "" {
+		renderDeepLink(c, resolution)
+		return
+	}
+
+	if status == http.StatusMovedPermanently {
+		c.Header("Cache-Control", "public, max-age="+strconv.Itoa(permanentRedirectMaxAge(link)))
+	}
+	c.Redirect(status, resolution.Target)
+}
+
+// renderDeepLink serves a page that tries to open the app and falls back
+// to the web target when nothing handles the app URL.
+func renderDeepLink(c *gin.Context, resolution *service.Resolution) {
+	c.Header("Cache-Control", "no-store")
+	renderPage(c, http.StatusOK, "deeplink.html", gin.H{
+		"Title": "Opening app",
+		// The app URL was checked against the allowed schemes when the
+		// link was created, so it is trusted in href.
+		"AppURL":      template.URL(resolution.AppURL),
+		"FallbackURL": resolution.Target,
+	})
+}
+
+// redirectStatus avoids permanent redirects for links whose answer can
+// change, since browsers cache a 301 indefinitely.
+func redirectStatus(link *service.Link) int {
+	if link.IsStable() {
+		return http.StatusMovedPermanently
+	}
+	return http.StatusFound
+}
+
+// permanentRedirectMaxAge bounds how many seconds browsers keep a 301, so
+// rules added later, deletion and expiry still reach earlier visitors.
+func permanentRedirectMaxAge(link *service.Link) int {
+	maxAge := int(permanentRedirectCacheTTL.Seconds())
+	if link.ExpiresAt > 0 {
+		maxAge = min(maxAge, max(0, int(time.Until(time.Unix(link.ExpiresAt, 0)).Seconds())))
+	}
+	return maxAge
+}
+
+func (h *URLHandler) renderPreview(c *gin.Context, link *service.Link) {
+	preview, err := h.service.PreviewLink(c.Request.Context(), link, h.visitorFromRequest(c, link))
+	if err != nil {
+		respondLookupError(c, err)
+		return
+	}
+
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) != gin.MIMEHTML {
+		c.JSON(http.StatusOK, preview)
 		return
 	}
 
-	c.Redirect(http.StatusMovedPermanently, originalURL)
+	renderPage(c, http.StatusOK, "preview.html", gin.H{
+		"Title":       "Link preview",
+		"Destination": preview.Destination,
+		"LinkTitle":   preview.Title,
+		"CreatedAt":   formatUnix(preview.CreatedAt),
+		"Clicks":      preview.Clicks,
+		"ContinueURL": linkPath(c, link.ShortID) + "?continue=1",
+	})
+}
+
+// UnlockURL godoc
+// @Summary      Unlock password-protected URL
+// @Description  Check the password of a protected short URL and redirect to the original URL
+// @Tags         URL
+// @Accept       x-www-form-urlencoded
+// @Produce      json,html
+// @Param        shortId   path      string  true  "Short URL ID"
+// @Param        password  formData  string  true  "Link password"
+// @Success      303       {string}  string  "Redirected to original URL"
+// @Failure      401       {object}  ErrorResponse  "Wrong password"
+// @Failure      404       {object}  ErrorResponse  "Unknown short ID"
+// @Failure      429       {object}  ErrorResponse  "Too many wrong passwords"
+// @Router       /{shortId} [post]
+// @Router       /{shortId}/{rest} [post]
+func (h *URLHandler) UnlockURL(c *gin.Context) {
+	shortID := c.Param("shortId")
+
+	link, err := h.service.GetLink(c.Request.Context(), shortID)
+	if err != nil {
+		respondLookupError(c, err)
+		return
+	}
+
+	if !checkPath(c, link) || !checkWindow(c, link) {
+		return
+	}
+
+	if link.IsProtected() {
+		err := h.service.VerifyPassword(c.Request.Context(), link, c.PostForm("password"), c.ClientIP())
+		switch {
+		case errors.Is(err, service.ErrPasswordLocked):
+			renderPasswordForm(c, http.StatusTooManyRequests, shortID, "Too many wrong passwords, try again later")
+			return
+		case errors.Is(err, service.ErrWrongPassword):
+			renderPasswordForm(c, http.StatusUnauthorized, shortID, "Wrong password")
+			return
+		case err != nil:
+			respondError(c, http.StatusInternalServerError, "Failed to check password")
+			return
+		}
+	}
+
+	h.redirect(c, link, http.StatusSeeOther)
+}
+
+func (h *URLHandler) visitorFromRequest(c *gin.Context, link *service.Link) *service.Visitor {
+	variant := service.NoVariant
+	if value, err := c.Cookie(variantCookie(link.ShortID)); err == nil {
+		if n, err := strconv.Atoi(value); err == nil {
+			variant = n
+		}
+	}
+
+	hops, _ := strconv.Atoi(c.Query(service.HopsQueryParam))
+
+	return &service.Visitor{
+		UserAgent:      c.Request.UserAgent(),
+		AcceptLanguage: c.GetHeader("Accept-Language"),
+		Country:        c.GetHeader(h.cfg.Links.CountryHeader),
+		Query:          c.Request.URL.Query(),
+		Path:           c.Param("rest"),
+		Time:           time.Now(),
+		Variant:        variant,
+		Hops:           hops,
+	}
+}
+
+// variantCookie names the cookie keeping a visitor on the same A/B variant
+// of a link.
+func variantCookie(shortID string) string {
+	return "sg_variant_" + shortID
+}
+
+// linkPath is the request path of the short link without any "+" preview
+// suffix.
+func linkPath(c *gin.Context, shortID string) string {
+	return "/" + shortID + c.Param("rest")
+}
+
+// checkPath rejects a trailing path on links that do not forward paths.
+func checkPath(c *gin.Context, link *service.Link) bool {
+	rest := c.Param("rest")
+	if rest == "" || rest == "/" || (link.ForwardPath && len(rest) <= service.MaxForwardedPathLength) {
+		return true
+	}
+
+	respondLookupError(c, service.ErrURLNotFound)
+	return false
+}
+
+// checkWindow reports whether the link is inside its activation window,
+// otherwise sending the visitor to its fallback URL or an error page.
+func checkWindow(c *gin.Context, link *service.Link) bool {
+	err := link.CheckWindow(time.Now())
+	if err == nil {
+		return true
+	}
+
+	if link.FallbackURL != "" {
+		c.Redirect(http.StatusFound, link.FallbackURL)
+		return false
+	}
+
+	respondLookupError(c, err)
+	return false
+}
+
+// isAutomated reports whether the request comes from a link preview
+// crawler or another bot, whose visits are not counted as clicks.
+func isAutomated(c *gin.Context) bool {
+	userAgent := c.Request.UserAgent()
+	return utils.IsLinkPreviewCrawler(userAgent) || utils.DetectDevice(userAgent) == utils.DeviceBot
+}
+
+// recordClick counts the visit and reports whether the redirect may go
+// ahead. Limited links fail closed; for the rest a failed counter only
+// gets logged.
+func (h *URLHandler) recordClick(c *gin.Context, link *service.Link, variant int) bool {
+	err := h.service.RecordClick(c.Request.Context(), link, variant)
+	if err == nil {
+		return true
+	}
+
+	if link.MaxClicks > 0 || errors.Is(err, service.ErrURLGone) {
+		respondLookupError(c, err)
+		return false
+	}
+
+	log.Printf("Failed to record click for %s: %v", link.ShortID, err)
+	return true
+}
+
+func renderPasswordForm(c *gin.Context, status int, shortID string, message string) {
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) != gin.MIMEHTML {
+		respondError(c, status, message)
+		return
+	}
+
+	renderPage(c, status, "password.html", gin.H{
+		"Title":   "Password required",
+		"Message": message,
+		"Action":  linkPath(c, shortID),
+	})
+}
+
+// DeleteURL godoc
+// @Summary      Delete short URL
+// @Description  Delete a short URL; later visits answer 410 Gone
+// @Tags         URL
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Produce      json
+// @Param        shortId  path      string  true  "Short URL ID"
+// @Success      204      "Deleted"
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Router       /api/v1/links/{shortId} [delete]
+func (h *URLHandler) DeleteURL(c *gin.Context) {
+	if err := h.service.DeleteURL(c.Request.Context(), c.Param("shortId"), principal(c)); err != nil {
+		respondLookupError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// principal returns the authenticated caller, or nil outside the API group.
+func principal(c *gin.Context) *service.Principal {
+	p, _ := middleware.GetPrincipal(c)
+	return p
+}
+
+func isLookupError(err error) bool {
+	return errors.Is(err, service.ErrURLGone) || errors.Is(err, service.ErrURLNotFound) ||
+		errors.Is(err, service.ErrURLNotActive) || errors.Is(err, service.ErrForbidden)
+}
+
+func respondLookupError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		respondError(c, http.StatusForbidden, "You do not have access to this link")
+	case errors.Is(err, service.ErrURLGone):
+		respondError(c, http.StatusGone, "This link has expired or was deleted")
+	case errors.Is(err, service.ErrURLNotActive):
+		respondError(c, http.StatusNotFound, "This link is not active yet")
+	case errors.Is(err, service.ErrURLNotFound):
+		respondError(c, http.StatusNotFound, "URL not found")
+	default:
+		respondError(c, http.StatusInternalServerError, "Failed to look up URL")
+	}
 }
 
 // GetMetrics godoc
@@ -90,10 +440,12 @@ func (h *URLHandler) RedirectURL(c *gin.Context) {
 // @Description  Returns cache statistics including hit ratio and total requests
 // @Tags         Metrics
 // @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Produce      json
 // @Router       /api/v1/metrics [get]
 func (h *URLHandler) GetMetrics(c *gin.Context) {
 	metrics := h.service.GetCacheMetrics()
+	auth := middleware.GetAuthMetrics()
 
 	hitRatio := 0.0
 	if metrics.Hits > 0 {
@@ -105,6 +457,8 @@ func (h *URLHandler) GetMetrics(c *gin.Context) {
 		"cache_errors":   metrics.Errors,
 		"total_requests": metrics.TotalRequests,
 		"hit_ratio":      hitRatio,
+		"auth_failures":  auth.Failures,
+		"auth_lockouts":  auth.Lockouts,
 		"timestamp":      time.Now().Unix(),
 	})
 }
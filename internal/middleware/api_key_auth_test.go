@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/william1nguyen/shortygo/internal/config"
+	"github.com/william1nguyen/shortygo/internal/service"
+)
+
+func TestAPIKeyAuthEnvKey(t *testing.T) {
+	redisCache, _ := newTestCache(t)
+	keys := service.NewAPIKeyService(config.AuthConfig{MaxFailures: 100, Lockout: time.Minute}, redisCache)
+	router := newAuthRouter(keys)
+
+	tests := []struct {
+		name     string
+		envKey   string
+		apiKey   string
+		wantCode int
+		wantKey  string
+	}{
+		{name: "matching key", envKey: "env-secret", apiKey: "env-secret", wantCode: http.StatusOK, wantKey: "env"},
+		{name: "wrong key of the same length", envKey: "env-secret", apiKey: "env-secreT", wantCode: http.StatusUnauthorized},
+		{name: "prefix of the key", envKey: "env-secret", apiKey: "env-secre", wantCode: http.StatusUnauthorized},
+		{name: "key with a suffix", envKey: "env-secret", apiKey: "env-secret2", wantCode: http.StatusUnauthorized},
+		{name: "missing key", envKey: "env-secret", wantCode: http.StatusUnauthorized},
+		{name: "no env key", apiKey: "env-secret", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("X_API_KEY", tt.envKey)
+
+			w := serveAuth(router, "203.0.113.1", tt.apiKey)
+			if w.Code != tt.wantCode {
+				t.Fatalf("got %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.wantKey != "" && w.Body.String() != tt.wantKey {
+				t.Errorf("principal key ID = %q, want %q", w.Body.String(), tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	t.Setenv("X_API_KEY", "")
+
+	redisCache, _ := newTestCache(t)
+	keys := service.NewAPIKeyService(config.AuthConfig{MaxFailures: 100, Lockout: time.Minute}, redisCache)
+
+	newKey := func(scopes ...string) string {
+		created, err := keys.CreateKey(context.Background(), &service.CreateAPIKeyRequest{
+			Name:   "test",
+			Owner:  "alice",
+			Scopes: scopes,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return created.Secret
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(APIKeyAuth(keys))
+	router.GET("/stats", RequireScope(service.ScopeStatsRead), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		apiKey   string
+		wantCode int
+	}{
+		{name: "scope granted", apiKey: newKey(service.ScopeStatsRead), wantCode: http.StatusOK},
+		{name: "admin", apiKey: newKey(service.ScopeAdmin), wantCode: http.StatusOK},
+		{name: "other scopes", apiKey: newKey(service.ScopeLinksWrite, service.ScopeLinksRead), wantCode: http.StatusForbidden},
+		{name: "no key", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+			if tt.apiKey != "" {
+				req.Header.Set("x-api-key", tt.apiKey)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("got %d, want %d", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/william1nguyen/shortygo/internal/cache"
+	"github.com/william1nguyen/shortygo/internal/config"
+	"github.com/william1nguyen/shortygo/internal/service"
+)
+
+// newTestCache starts an in-memory Redis for one test.
+func newTestCache(t *testing.T) (*cache.RedisCache, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	redisCache, err := cache.NewRedisCache(config.RedisConfig{Addrs: []string{server.Addr()}})
+	if err != nil {
+		t.Fatalf("NewRedisCache: %v", err)
+	}
+	t.Cleanup(redisCache.Close)
+
+	return redisCache, server
+}
+
+// newAuthRouter serves GET /ok behind the lockout and API key auth.
+func newAuthRouter(keys *service.APIKeyService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(AuthLockout(keys), APIKeyAuth(keys))
+	router.GET("/ok", func(c *gin.Context) {
+		principal, _ := GetPrincipal(c)
+		c.String(http.StatusOK, principal.KeyID)
+	})
+	return router
+}
+
+func serveAuth(router http.Handler, clientIP string, apiKey string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+	req.RemoteAddr = clientIP + ":40000"
+	if apiKey != "" {
+		req.Header.Set("x-api-key", apiKey)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthLockoutConcurrentFailures(t *testing.T) {
+	t.Setenv("X_API_KEY", "env-secret")
+
+	redisCache, _ := newTestCache(t)
+	keys := service.NewAPIKeyService(config.AuthConfig{MaxFailures: 3, Lockout: time.Minute}, redisCache)
+	router := newAuthRouter(keys)
+
+	const attempts = 20
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		statuses = map[int]int{}
+	)
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			code := serveAuth(router, "203.0.113.1", "wrong-secret").Code
+			mu.Lock()
+			statuses[code]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if statuses[http.StatusUnauthorized] != 3 || statuses[http.StatusTooManyRequests] != attempts-3 {
+		t.Fatalf("statuses = %v, want 3 x 401 and %d x 429", statuses, attempts-3)
+	}
+
+	if w := serveAuth(router, "203.0.113.1", "env-secret"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("valid key from a locked out IP got %d, want 429", w.Code)
+	}
+	if w := serveAuth(router, "203.0.113.2", "env-secret"); w.Code != http.StatusOK {
+		t.Errorf("valid key from another IP got %d, want 200", w.Code)
+	}
+}
+
+func TestAuthLockoutForgivesSuccesses(t *testing.T) {
+	t.Setenv("X_API_KEY", "env-secret")
+
+	redisCache, _ := newTestCache(t)
+	keys := service.NewAPIKeyService(config.AuthConfig{MaxFailures: 3, Lockout: time.Minute}, redisCache)
+	router := newAuthRouter(keys)
+
+	for i := range 10 {
+		if w := serveAuth(router, "203.0.113.1", "env-secret"); w.Code != http.StatusOK {
+			t.Fatalf("request %d got %d, want 200", i+1, w.Code)
+		}
+	}
+	for i := range 3 {
+		if w := serveAuth(router, "203.0.113.1", "wrong-secret"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("failure %d got %d, want 401", i+1, w.Code)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/william1nguyen/shortygo/internal/service"
+)
+
+// JWTAuth authenticates Authorization: Bearer tokens. Requests without a
+// bearer token pass through unauthenticated, so it can run in front of
+// APIKeyAuth to accept either credential.
+func JWTAuth(verifier *service.TokenVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scheme, token, found := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") {
+			c.Next()
+			return
+		}
+
+		principal, err := verifier.Verify(c.Request.Context(), strings.TrimSpace(token))
+		if errors.Is(err, service.ErrKeySetFailed) {
+			log.Printf("Bearer authentication unavailable: %v", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":   "Authentication unavailable",
+				"message": "Could not verify the bearer token, try again later",
+			})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			failAuth(c, "Invalid token", "The provided bearer token is not valid", err.Error())
+			return
+		}
+
+		c.Set(principalContextKey, principal)
+		c.Next()
+	}
+}
@@ -1,41 +1,264 @@
 package middleware
 
 import (
+	"container/list"
+	"context"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/william1nguyen/shortygo/internal/config"
+	"github.com/william1nguyen/shortygo/internal/service"
 	"golang.org/x/time/rate"
 )
 
-var (
-	limiters = make(map[string]*rate.Limiter)
-	mu       sync.RWMutex
+const (
+	minLimiterCleanupInterval = time.Second
+
+	// redisLimiterRetry is how long RedisLimiter stays on its local
+	// fallback before trying Redis again, so an outage does not add a
+	// Redis timeout to every request.
+	redisLimiterRetry = 5 * time.Second
 )
 
-func RateLimiter(requestsPerSecond float64) gin.HandlerFunc {
+type limiterEntry struct {
+	key      string
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// limiterStore keeps one limiter per client, bounded to maxEntries by
+// evicting the least recently seen client and pruned of clients idle for
+// longer than idleTTL. A forgotten client starts over with a full bucket.
+type limiterStore struct {
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	recent     *list.List
+	maxEntries int
+	idleTTL    time.Duration
+}
+
+func newLimiterStore(maxEntries int, idleTTL time.Duration) *limiterStore {
+	return &limiterStore{
+		entries:    make(map[string]*list.Element),
+		recent:     list.New(),
+		maxEntries: maxEntries,
+		idleTTL:    idleTTL,
+	}
+}
+
+// get returns the limiter of key, creating it under the same lock as the
+// lookup so concurrent first requests share one limiter. An existing
+// limiter is adjusted when the policy has changed, e.g. for an API key
+// given a new rate limit.
+func (s *limiterStore) get(key string, now time.Time, policy service.RateLimitPolicy) *rate.Limiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limit := rate.Limit(policy.RequestsPerSecond)
+
+	if elem, ok := s.entries[key]; ok {
+		entry := elem.Value.(*limiterEntry)
+		entry.lastSeen = now
+		s.recent.MoveToFront(elem)
+
+		if entry.limiter.Limit() != limit || entry.limiter.Burst() != policy.Burst {
+			entry.limiter.SetLimitAt(now, limit)
+			entry.limiter.SetBurstAt(now, policy.Burst)
+		}
+		return entry.limiter
+	}
+
+	for s.recent.Len() >= s.maxEntries {
+		s.remove(s.recent.Back())
+	}
+
+	entry := &limiterEntry{key: key, limiter: rate.NewLimiter(limit, policy.Burst), lastSeen: now}
+	s.entries[key] = s.recent.PushFront(entry)
+	return entry.limiter
+}
+
+// evictIdle drops clients not seen within idleTTL of now.
+func (s *limiterStore) evictIdle(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for elem := s.recent.Back(); elem != nil; elem = s.recent.Back() {
+		if now.Sub(elem.Value.(*limiterEntry).lastSeen) < s.idleTTL {
+			return
+		}
+		s.remove(elem)
+	}
+}
+
+func (s *limiterStore) remove(elem *list.Element) {
+	s.recent.Remove(elem)
+	delete(s.entries, elem.Value.(*limiterEntry).key)
+}
+
+// cleanup evicts idle clients for the lifetime of the process.
+func (s *limiterStore) cleanup() {
+	interval := max(s.idleTTL/2, minLimiterCleanupInterval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		s.evictIdle(now)
+	}
+}
+
+// Limiter decides whether a client, identified by key, may make another
+// request under policy.
+type Limiter interface {
+	Allow(ctx context.Context, key string, policy service.RateLimitPolicy) (*service.RateLimitResult, error)
+}
+
+// LocalLimiter limits requests seen by this instance only.
+type LocalLimiter struct {
+	store *limiterStore
+}
+
+func NewLocalLimiter() *LocalLimiter {
+	cfg := config.Load()
+
+	store := newLimiterStore(cfg.RateLimit.MaxEntries, cfg.RateLimit.IdleTTL)
+	go store.cleanup()
+
+	return &LocalLimiter{store: store}
+}
+
+func (l *LocalLimiter) Allow(_ context.Context, key string, policy service.RateLimitPolicy) (*service.RateLimitResult, error) {
+	now := time.Now()
+	limiter := l.store.get(policy.Name+":"+key, now, policy)
+
+	result := &service.RateLimitResult{Limit: policy.Burst, Allowed: limiter.AllowN(now, 1)}
+	tokens := limiter.TokensAt(now)
+	if tokens > 0 {
+		result.Remaining = int(tokens)
+	}
+	if !result.Allowed {
+		result.RetryAfter = time.Duration((1 - tokens) / policy.RequestsPerSecond * float64(time.Second))
+	}
+
+	return result, nil
+}
+
+// RedisLimiter shares limits across every instance through Redis. While
+// Redis is unreachable it falls back to limiting each instance on its own.
+type RedisLimiter struct {
+	limits   *service.RateLimitService
+	fallback *LocalLimiter
+	// retryAt is when to try Redis again after a failure, in Unix
+	// nanoseconds; zero while Redis is healthy.
+	retryAt atomic.Int64
+}
+
+func NewRedisLimiter(limits *service.RateLimitService) *RedisLimiter {
+	return &RedisLimiter{
+		limits:   limits,
+		fallback: NewLocalLimiter(),
+	}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, policy service.RateLimitPolicy) (*service.RateLimitResult, error) {
+	now := time.Now()
+	retryAt := l.retryAt.Load()
+	if retryAt != 0 && now.UnixNano() < retryAt {
+		return l.fallback.Allow(ctx, key, policy)
+	}
+
+	result, err := l.limits.Allow(ctx, key, policy)
+	if err != nil {
+		if l.retryAt.Swap(now.Add(redisLimiterRetry).UnixNano()) == 0 {
+			log.Printf("Rate limiting locally, Redis is unavailable: %v", err)
+		}
+		return l.fallback.Allow(ctx, key, policy)
+	}
+
+	if l.retryAt.Swap(0) != 0 {
+		log.Printf("Rate limiting through Redis again")
+	}
+	return result, nil
+}
+
+// RateLimit limits clients with limiter under policy, or the policy routes
+// holds for the matched route pattern. Callers authenticated with an API
+// key are limited per key, using the key's own rate limit when it has one,
+// and bearer token callers per token subject; everyone else is limited per
+// IP.
+func RateLimit(limiter Limiter, policy service.RateLimitPolicy, routes map[string]service.RateLimitPolicy) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
+		applied, ok := routes[c.FullPath()]
+		if !ok {
+			applied = policy
+		}
 
-		mu.RLock()
-		limiter, exists := limiters[ip]
-		mu.RUnlock()
+		key := "ip:" + c.ClientIP()
+		if principal, ok := GetPrincipal(c); ok && principal.KeyID != "" {
+			key = "key:" + principal.KeyID
+			if principal.RateLimit > 0 {
+				applied = service.NewRateLimitPolicy(applied.Name, principal.RateLimit)
+			}
+		}
 
-		if !exists {
-			mu.Lock()
-			limiter = rate.NewLimiter(rate.Limit(requestsPerSecond), int(requestsPerSecond*2))
-			limiters[ip] = limiter
-			mu.Unlock()
+		result, err := limiter.Allow(c.Request.Context(), key, applied)
+		if err != nil {
+			log.Printf("Skipping rate limit: %v", err)
+			c.Next()
+			return
 		}
 
-		if !limiter.Allow() {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "Too many requests",
-			})
-			c.Abort()
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+
+		if !result.Allowed {
+			rejectRateLimited(c, result, "Too many requests")
 			return
 		}
 
 		c.Next()
 	}
 }
+
+// DailyQuota enforces the daily quota of API keys that have one. It runs
+// after RateLimit so requests rejected there do not use up the quota.
+func DailyQuota(limits *service.RateLimitService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := GetPrincipal(c)
+		if !ok || principal.DailyQuota <= 0 {
+			c.Next()
+			return
+		}
+
+		result, err := limits.ConsumeQuota(c.Request.Context(), principal.KeyID, principal.DailyQuota)
+		if err != nil {
+			log.Printf("Skipping daily quota: %v", err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-Quota-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-Quota-Remaining", strconv.Itoa(result.Remaining))
+
+		if !result.Allowed {
+			rejectRateLimited(c, result, "Daily quota exceeded")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func rejectRateLimited(c *gin.Context, result *service.RateLimitResult, title string) {
+	retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(max(1, retryAfter)))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error": title,
+	})
+	c.Abort()
+}
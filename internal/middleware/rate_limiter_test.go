@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/william1nguyen/shortygo/internal/service"
+	"golang.org/x/time/rate"
+)
+
+func TestLimiterStoreEvictsLeastRecentlySeen(t *testing.T) {
+	store := newLimiterStore(2, time.Hour)
+	policy := service.NewRateLimitPolicy("test", 1)
+	now := time.Now()
+
+	a := store.get("a", now, policy)
+	store.get("b", now, policy)
+	if store.get("a", now, policy) != a {
+		t.Fatal("get() created a new limiter for a known client")
+	}
+
+	// b is now the least recently seen client.
+	store.get("c", now, policy)
+	if store.recent.Len() != 2 || len(store.entries) != 2 {
+		t.Fatalf("store holds %d clients (%d indexed), want 2", store.recent.Len(), len(store.entries))
+	}
+	if _, ok := store.entries["b"]; ok {
+		t.Error("b was kept although it was the least recently seen client")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := store.entries[key]; !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+}
+
+func TestLimiterStoreEvictsIdleClients(t *testing.T) {
+	store := newLimiterStore(10, time.Minute)
+	policy := service.NewRateLimitPolicy("test", 1)
+	start := time.Now()
+
+	store.get("old", start, policy)
+	store.get("recent", start.Add(40*time.Second), policy)
+
+	store.evictIdle(start.Add(time.Minute))
+	if _, ok := store.entries["old"]; ok {
+		t.Error("a client idle for the whole idle TTL was kept")
+	}
+	if _, ok := store.entries["recent"]; !ok {
+		t.Error("a client seen within the idle TTL was evicted")
+	}
+
+	store.evictIdle(start.Add(2 * time.Minute))
+	if store.recent.Len() != 0 || len(store.entries) != 0 {
+		t.Errorf("store holds %d clients after they all went idle, want 0", store.recent.Len())
+	}
+}
+
+func TestLimiterStoreBoundUnderConcurrency(t *testing.T) {
+	store := newLimiterStore(50, time.Hour)
+	policy := service.NewRateLimitPolicy("test", 1)
+
+	var wg sync.WaitGroup
+	for worker := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 500 {
+				store.get(fmt.Sprintf("%d:%d", worker, i), time.Now(), policy)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if store.recent.Len() != 50 || len(store.entries) != 50 {
+		t.Fatalf("store holds %d clients (%d indexed), want 50", store.recent.Len(), len(store.entries))
+	}
+}
+
+func TestLimiterStoreAppliesChangedPolicy(t *testing.T) {
+	store := newLimiterStore(10, time.Hour)
+	now := time.Now()
+
+	limiter := store.get("key", now, service.NewRateLimitPolicy("test", 1))
+	store.get("key", now, service.NewRateLimitPolicy("test", 10))
+
+	if limiter.Limit() != rate.Limit(10) || limiter.Burst() != 20 {
+		t.Errorf("limiter has limit %v and burst %d, want 10 and 20", limiter.Limit(), limiter.Burst())
+	}
+}
+
+func TestRedisLimiterFallsBackToLocal(t *testing.T) {
+	redisCache, server := newTestCache(t)
+	limiter := NewRedisLimiter(service.NewRateLimitService(redisCache))
+	policy := service.NewRateLimitPolicy("test", 1)
+	ctx := context.Background()
+
+	if _, err := limiter.Allow(ctx, "client", policy); err != nil {
+		t.Fatalf("Allow() = %v", err)
+	}
+	if !server.Exists("ratelimit:{test:client}") {
+		t.Fatal("Allow() did not limit through Redis while it was up")
+	}
+
+	server.Close()
+
+	for i := range policy.Burst {
+		result, err := limiter.Allow(ctx, "client", policy)
+		if err != nil {
+			t.Fatalf("Allow() with Redis down = %v, want the local fallback", err)
+		}
+		if !result.Allowed {
+			t.Fatalf("request %d of a burst of %d was rejected by the fallback", i+1, policy.Burst)
+		}
+	}
+	result, err := limiter.Allow(ctx, "client", policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed {
+		t.Error("the fallback let a request past the burst through")
+	}
+	if limiter.retryAt.Load() == 0 {
+		t.Fatal("Allow() did not back off from Redis")
+	}
+
+	if err := server.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	server.FlushAll()
+
+	// Still backing off, so Redis is not asked yet.
+	if _, err := limiter.Allow(ctx, "other", policy); err != nil {
+		t.Fatal(err)
+	}
+	if server.Exists("ratelimit:{test:other}") {
+		t.Error("Allow() went to Redis before the retry delay passed")
+	}
+
+	limiter.retryAt.Store(time.Now().Add(-time.Second).UnixNano())
+	if _, err := limiter.Allow(ctx, "other", policy); err != nil {
+		t.Fatal(err)
+	}
+	if !server.Exists("ratelimit:{test:other}") {
+		t.Error("Allow() did not return to Redis after the retry delay")
+	}
+	if limiter.retryAt.Load() != 0 {
+		t.Error("Allow() kept backing off once Redis answered")
+	}
+}
+
+func TestRateLimitPolicies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	limiter := &LocalLimiter{store: newLimiterStore(100, time.Hour)}
+	routes := map[string]service.RateLimitPolicy{
+		"/shorten": service.NewRateLimitPolicy("shorten", 1),
+	}
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if keyID := c.GetHeader("Test-Key"); keyID != "" {
+			c.Set(principalContextKey, &service.Principal{KeyID: keyID, RateLimit: 2})
+		}
+	})
+	router.Use(RateLimit(limiter, service.NewRateLimitPolicy("api", 5), routes))
+	router.GET("/shorten", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/stats", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	serve := func(path string, keyID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "203.0.113.1:40000"
+		if keyID != "" {
+			req.Header.Set("Test-Key", keyID)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		keyID     string
+		wantBurst int
+	}{
+		{name: "route policy", path: "/shorten", wantBurst: 2},
+		{name: "default policy", path: "/stats", wantBurst: 10},
+		{name: "API key rate limit", path: "/stats", keyID: "key1", wantBurst: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range tt.wantBurst {
+				w := serve(tt.path, tt.keyID)
+				if w.Code != http.StatusOK {
+					t.Fatalf("request %d got %d, want 200", i+1, w.Code)
+				}
+				if got := w.Header().Get("RateLimit-Limit"); got != strconv.Itoa(tt.wantBurst) {
+					t.Fatalf("RateLimit-Limit = %s, want %d", got, tt.wantBurst)
+				}
+			}
+
+			w := serve(tt.path, tt.keyID)
+			if w.Code != http.StatusTooManyRequests {
+				t.Fatalf("request past the burst got %d, want 429", w.Code)
+			}
+			if w.Header().Get("Retry-After") == "" || w.Header().Get("RateLimit-Remaining") != "0" {
+				t.Errorf("rejected request has headers %v", w.Header())
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/william1nguyen/shortygo/internal/config"
+	"github.com/william1nguyen/shortygo/internal/service"
+)
+
+const (
+	SignatureHeader          = "X-Signature"
+	SignatureKeyIDHeader     = "X-Signature-Key-Id"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	SignatureNonceHeader     = "X-Signature-Nonce"
+	ContentSHA256Header      = "X-Content-SHA256"
+
+	maxSignedBodySize = 1 << 20
+)
+
+// SignatureAuth authenticates requests signed with an API key, see
+// service.SignRequest. Requests without an X-Signature header pass through
+// unauthenticated, so it can run in front of APIKeyAuth.
+func SignatureAuth(keys *service.APIKeyService) gin.HandlerFunc {
+	maxSkew := config.Load().Auth.SignatureMaxSkew
+
+	return func(c *gin.Context) {
+		signature := c.GetHeader(SignatureHeader)
+		if signature == "" {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxSignedBodySize))
+		if err != nil {
+			rejectSignature(c, http.StatusRequestEntityTooLarge, "Request body too large",
+				"Signed request bodies are limited to 1 MiB")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(body)
+		bodyHash := hex.EncodeToString(sum[:])
+		if !strings.EqualFold(c.GetHeader(ContentSHA256Header), bodyHash) {
+			failAuth(c, "Invalid signature", "X-Content-SHA256 does not match the request body",
+				"body hash mismatch")
+			return
+		}
+
+		timestamp, err := strconv.ParseInt(c.GetHeader(SignatureTimestampHeader), 10, 64)
+		if err != nil {
+			failAuth(c, "Invalid signature", "X-Signature-Timestamp must be a Unix timestamp",
+				"malformed timestamp")
+			return
+		}
+
+		key, err := keys.VerifySignature(c.Request.Context(), &service.SignedRequest{
+			KeyID:      c.GetHeader(SignatureKeyIDHeader),
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.RequestURI(),
+			Timestamp:  timestamp,
+			Nonce:      c.GetHeader(SignatureNonceHeader),
+			BodySHA256: bodyHash,
+			Signature:  signature,
+		}, maxSkew)
+		switch {
+		case errors.Is(err, service.ErrReplayedRequest):
+			failAuth(c, "Replayed request", "This nonce was already used, sign every request with a new one",
+				err.Error())
+			return
+		case errors.Is(err, service.ErrInvalidSignature):
+			failAuth(c, "Invalid signature", "The request signature is not valid", err.Error())
+			return
+		case err != nil:
+			rejectSignature(c, http.StatusServiceUnavailable, "Authentication unavailable",
+				"Could not verify the request signature, try again later")
+			return
+		}
+
+		c.Set(principalContextKey, key.Principal())
+		c.Next()
+	}
+}
+
+func rejectSignature(c *gin.Context, status int, title string, message string) {
+	c.JSON(status, gin.H{
+		"error":   title,
+		"message": message,
+	})
+	c.Abort()
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// resolveTimeout bounds looking up the addresses of a destination host.
+const resolveTimeout = 3 * time.Second
+
+// Resolver looks up the addresses of a host name. *net.Resolver is one.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network string, host string) ([]netip.Addr, error)
+}
+
+// nonPublicPrefixes are the special-purpose ranges the netip.Addr methods
+// do not already rule out.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // reserved
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, may wrap any IPv4 address
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2002::/16"), // 6to4, likewise
+}
+
+// privateAllowlist holds the non-public destinations still allowed.
+type privateAllowlist struct {
+	prefixes []netip.Prefix
+	domains  []string
+}
+
+// parsePrivateAllowlist sorts entries into CIDRs, single IPs and domains.
+func parsePrivateAllowlist(entries []string) privateAllowlist {
+	var allowlist privateAllowlist
+	for _, entry := range entries {
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			allowlist.prefixes = append(allowlist.prefixes, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(entry); err == nil {
+			allowlist.prefixes = append(allowlist.prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		} else {
+			allowlist.domains = append(allowlist.domains, normalizeHost(entry))
+		}
+	}
+	return allowlist
+}
+
+func (a privateAllowlist) contains(addr netip.Addr) bool {
+	for _, prefix := range a.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAddresses rejects hosts that are, or resolve to, addresses outside
+// the public internet, unless allowlisted. Hosts are resolved when links
+// are created; a name re-pointed later is not caught.
+func (p *DestinationPolicy) checkAddresses(host string) error {
+	if _, ok := matchDomain(host, p.privateAllowed.domains); ok {
+		return nil
+	}
+
+	addrs, err := p.lookupHost(host)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDestinationBlocked, err)
+	}
+
+	for _, addr := range addrs {
+		addr = addr.Unmap().WithZone("")
+		if isPublicAddr(addr) || p.privateAllowed.contains(addr) {
+			continue
+		}
+		if _, err := netip.ParseAddr(host); err == nil {
+			return fmt.Errorf("%w: address %s is not public", ErrDestinationBlocked, addr)
+		}
+		return fmt.Errorf("%w: %s resolves to non-public address %s", ErrDestinationBlocked, host, addr)
+	}
+
+	return nil
+}
+
+func (p *DestinationPolicy) lookupHost(host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr}, nil
+	}
+
+	// Browsers read hosts such as 127.1 or 0x7f000001 as IPv4 addresses,
+	// while no real domain ends in a numeric label.
+	labels := strings.Split(host, ".")
+	if isNumericLabel(labels[len(labels)-1]) {
+		return nil, fmt.Errorf("numeric host %s is not a valid address", host)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	addrs, err := p.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve %s: %w", host, err)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("could not resolve %s", host)
+	}
+	return addrs, nil
+}
+
+func isPublicAddr(addr netip.Addr) bool {
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func isNumericLabel(label string) bool {
+	digits := "0123456789"
+	if hex, ok := strings.CutPrefix(label, "0x"); ok {
+		label, digits = hex, "0123456789abcdef"
+	}
+	return label != "" && strings.Trim(label, digits) == ""
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/william1nguyen/shortygo/internal/config"
+)
+
+// stubResolver answers lookups from a fixed table, so no test touches DNS.
+type stubResolver map[string][]string
+
+func (r stubResolver) LookupNetIP(_ context.Context, _ string, host string) ([]netip.Addr, error) {
+	values, ok := r[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+
+	addrs := make([]netip.Addr, len(values))
+	for i, value := range values {
+		addrs[i] = netip.MustParseAddr(value)
+	}
+	return addrs, nil
+}
+
+func TestDestinationPolicyBlocksPrivateAddresses(t *testing.T) {
+	resolver := stubResolver{
+		"public.example":    {"93.184.216.34", "2606:2800:220:1::1"},
+		"intranet.example":  {"10.0.0.5"},
+		"mixed.example":     {"93.184.216.34", "192.168.1.10"},
+		"metadata.example":  {"169.254.169.254"},
+		"ula.example":       {"fd12:3456::1"},
+		"wiki.corp.example": {"10.20.0.1"},
+		"lab.example":       {"10.1.2.3"},
+	}
+
+	policy, err := NewDestinationPolicy(config.PolicyConfig{
+		BlockPrivate:     true,
+		PrivateAllowlist: []string{"10.1.0.0/16", "fd00::5", "corp.example"},
+	}, resolver)
+	if err != nil {
+		t.Fatalf("NewDestinationPolicy: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		blocked bool
+	}{
+		{name: "public IPv4", url: "http://8.8.8.8/"},
+		{name: "public IPv6", url: "http://[2606:4700::1111]/"},
+		{name: "public host", url: "https://public.example/path"},
+		{name: "IPv4 loopback", url: "http://127.0.0.1/", blocked: true},
+		{name: "IPv6 loopback", url: "http://[::1]/", blocked: true},
+		{name: "unspecified", url: "http://0.0.0.0/", blocked: true},
+		{name: "RFC1918 10/8", url: "http://10.9.8.7/", blocked: true},
+		{name: "RFC1918 172.16/12", url: "http://172.16.0.1/", blocked: true},
+		{name: "RFC1918 192.168/16", url: "http://192.168.0.1/", blocked: true},
+		{name: "link-local metadata", url: "http://169.254.169.254/latest/meta-data", blocked: true},
+		{name: "IPv6 link-local", url: "http://[fe80::1]/", blocked: true},
+		{name: "IPv6 ULA", url: "http://[fd12:3456::1]/", blocked: true},
+		{name: "IPv4-mapped private", url: "http://[::ffff:10.0.0.1]/", blocked: true},
+		{name: "IPv4-mapped loopback", url: "http://[::ffff:127.0.0.1]/", blocked: true},
+		{name: "carrier-grade NAT", url: "http://100.64.0.1/", blocked: true},
+		{name: "shorthand IPv4", url: "http://127.1/", blocked: true},
+		{name: "hex IPv4", url: "http://0x7f000001/", blocked: true},
+		{name: "host resolving to private", url: "http://intranet.example/", blocked: true},
+		{name: "host resolving to metadata", url: "http://metadata.example/", blocked: true},
+		{name: "host resolving to ULA", url: "http://ula.example/", blocked: true},
+		{name: "host resolving to public and private", url: "http://mixed.example/", blocked: true},
+		{name: "unresolvable host", url: "http://missing.example/", blocked: true},
+		{name: "allowlisted CIDR", url: "http://10.1.200.1/"},
+		{name: "allowlisted IPv6", url: "http://[fd00::5]/"},
+		{name: "allowlisted domain", url: "http://wiki.corp.example/"},
+		{name: "host resolving into allowlisted CIDR", url: "http://lab.example/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = policy.Check(u)
+			if tt.blocked && !errors.Is(err, ErrDestinationBlocked) {
+				t.Fatalf("Check(%s) = %v, want ErrDestinationBlocked", tt.url, err)
+			}
+			if !tt.blocked && err != nil {
+				t.Fatalf("Check(%s) = %v, want allowed", tt.url, err)
+			}
+		})
+	}
+}
+
+func TestDestinationPolicyAllowsPrivateWhenDisabled(t *testing.T) {
+	policy, err := NewDestinationPolicy(config.PolicyConfig{}, stubResolver{})
+	if err != nil {
+		t.Fatalf("NewDestinationPolicy: %v", err)
+	}
+
+	u, _ := url.Parse("http://127.0.0.1/")
+	if err := policy.Check(u); err != nil {
+		t.Fatalf("Check(%s) = %v, want allowed", u, err)
+	}
+}
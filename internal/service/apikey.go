@@ -0,0 +1,392 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/william1nguyen/shortygo/internal/cache"
+	"github.com/william1nguyen/shortygo/internal/config"
+)
+
+const (
+	ScopeLinksWrite = "links:write"
+	ScopeLinksRead  = "links:read"
+	ScopeStatsRead  = "stats:read"
+	// ScopeAdmin grants every other scope.
+	ScopeAdmin = "admin"
+
+	apiKeyPrefix = "sg_"
+	apiKeySetKey = "apikeys"
+
+	DefaultRotationGracePeriod = 24 * time.Hour
+	MaxRotationGracePeriod     = 30 * 24 * time.Hour
+)
+
+var Scopes = []string{ScopeLinksWrite, ScopeLinksRead, ScopeStatsRead, ScopeAdmin}
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// APIKeyService manages the API keys clients authenticate with. Only a
+// SHA-256 hash of each secret is stored; the secret itself is shown once,
+// when the key is created.
+type APIKeyService struct {
+	auth  config.AuthConfig
+	cache *cache.RedisCache
+}
+
+type APIKey struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Owner      string   `json:"owner"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  int64    `json:"created_at"`
+	LastUsedAt int64    `json:"last_used_at,omitempty"`
+	RevokedAt  int64    `json:"revoked_at,omitempty"`
+
+	// RateLimit, in requests per second, replaces the route rate limits
+	// for this key when set. DailyQuota caps its requests per UTC day.
+	RateLimit  float64 `json:"rate_limit,omitempty"`
+	DailyQuota int     `json:"daily_quota,omitempty"`
+
+	// DefaultQueryParams are added to the query parameters of the links
+	// created with this key, on top of the configured defaults.
+	DefaultQueryParams map[string]string `json:"default_query_params,omitempty"`
+}
+
+type storedAPIKey struct {
+	APIKey
+	Hash string `json:"hash"`
+	// PreviousHash is the secret replaced by the last rotation, still
+	// accepted until PreviousExpiresAt.
+	PreviousHash      string `json:"previous_hash,omitempty"`
+	PreviousExpiresAt int64  `json:"previous_expires_at,omitempty"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name       string   `json:"name" binding:"required" example:"billing-service"`
+	Owner      string   `json:"owner" binding:"required" example:"billing-team"`
+	Scopes     []string `json:"scopes" binding:"required" example:"links:write"`
+	RateLimit  float64  `json:"rate_limit,omitempty" binding:"gte=0" example:"10"`
+	DailyQuota int      `json:"daily_quota,omitempty" binding:"gte=0" example:"10000"`
+
+	DefaultQueryParams map[string]string `json:"default_query_params,omitempty"`
+}
+
+type CreateAPIKeyResponse struct {
+	APIKey
+	// Secret is the key to send in the x-api-key header. It cannot be
+	// retrieved again.
+	Secret string `json:"secret"`
+	// SigningSecret signs requests in place of sending Secret, see
+	// SignRequest. It is only issued when request signing is enabled.
+	SigningSecret string `json:"signing_secret,omitempty"`
+}
+
+type RotateAPIKeyRequest struct {
+	// GracePeriod is how many seconds the old secret keeps working,
+	// DefaultRotationGracePeriod when unset. Zero cuts it off at once.
+	GracePeriod *int `json:"grace_period,omitempty" example:"86400"`
+}
+
+type RotateAPIKeyResponse struct {
+	APIKey
+	Secret        string `json:"secret"`
+	SigningSecret string `json:"signing_secret,omitempty"`
+	// PreviousExpiresAt is when the old secret stops working.
+	PreviousExpiresAt int64 `json:"previous_expires_at"`
+}
+
+func NewAPIKeyService(auth config.AuthConfig, cache *cache.RedisCache) *APIKeyService {
+	return &APIKeyService{auth: auth, cache: cache}
+}
+
+// HasScope reports whether scopes grant scope, directly or through admin.
+func HasScope(scopes []string, scope string) bool {
+	return slices.Contains(scopes, ScopeAdmin) || slices.Contains(scopes, scope)
+}
+
+func (k *APIKey) IsRevoked() bool {
+	return k.RevokedAt > 0
+}
+
+func (s *APIKeyService) CreateKey(ctx context.Context, req *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+	if len(req.Scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate key ID: %w", err)
+	}
+	id := hex.EncodeToString(idBytes)
+
+	secret, err := newAPIKeySecret(id)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &storedAPIKey{
+		APIKey: APIKey{
+			ID:         id,
+			Name:       req.Name,
+			Owner:      req.Owner,
+			Scopes:     req.Scopes,
+			RateLimit:  req.RateLimit,
+			DailyQuota: req.DailyQuota,
+			CreatedAt:  time.Now().Unix(),
+
+			DefaultQueryParams: req.DefaultQueryParams,
+		},
+		Hash: hashAPIKey(secret),
+	}
+
+	if err := s.saveKey(ctx, key); err != nil {
+		return nil, err
+	}
+
+	if err := s.cache.SAdd(ctx, apiKeySetKey, id); err != nil {
+		return nil, fmt.Errorf("failed to store API key: %w", err)
+	}
+
+	return &CreateAPIKeyResponse{
+		APIKey:        key.APIKey,
+		Secret:        secret,
+		SigningSecret: s.signingSecret(key.Hash),
+	}, nil
+}
+
+// Authenticate returns the key a secret belongs to, or ErrInvalidAPIKey
+// when it is unknown or revoked.
+func (s *APIKeyService) Authenticate(ctx context.Context, secret string) (*APIKey, error) {
+	id, ok := parseAPIKeyID(secret)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.loadKey(ctx, id)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !key.matches(secret, time.Now()) || key.IsRevoked() {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// Losing a last-used timestamp is not worth failing the request over.
+	_ = s.cache.Set(ctx, apiKeyLastUsedKey(id), strconv.FormatInt(time.Now().Unix(), 10), 0)
+
+	return &key.APIKey, nil
+}
+
+func (s *APIKeyService) GetKey(ctx context.Context, id string) (*APIKey, error) {
+	key, err := s.loadKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	key.LastUsedAt = s.lastUsed(ctx, id)
+	return &key.APIKey, nil
+}
+
+func (s *APIKeyService) ListKeys(ctx context.Context) ([]*APIKey, error) {
+	ids, err := s.cache.SMembers(ctx, apiKeySetKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	keys := make([]*APIKey, 0, len(ids))
+	for _, id := range ids {
+		key, err := s.GetKey(ctx, id)
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	slices.SortFunc(keys, func(a, b *APIKey) int {
+		return int(a.CreatedAt - b.CreatedAt)
+	})
+
+	return keys, nil
+}
+
+// RotateKey issues a new secret for a key. The old secret keeps working
+// for the grace period so clients can switch over without downtime.
+func (s *APIKeyService) RotateKey(ctx context.Context, id string, gracePeriod time.Duration) (*RotateAPIKeyResponse, error) {
+	if gracePeriod < 0 || gracePeriod > MaxRotationGracePeriod {
+		return nil, fmt.Errorf("grace period must be between 0 and %v", MaxRotationGracePeriod)
+	}
+
+	key, err := s.loadKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.IsRevoked() {
+		return nil, fmt.Errorf("API key %s is revoked", id)
+	}
+
+	secret, err := newAPIKeySecret(id)
+	if err != nil {
+		return nil, err
+	}
+
+	key.PreviousHash = key.Hash
+	key.PreviousExpiresAt = time.Now().Add(gracePeriod).Unix()
+	key.Hash = hashAPIKey(secret)
+
+	if err := s.saveKey(ctx, key); err != nil {
+		return nil, err
+	}
+
+	return &RotateAPIKeyResponse{
+		APIKey:            key.APIKey,
+		Secret:            secret,
+		SigningSecret:     s.signingSecret(key.Hash),
+		PreviousExpiresAt: key.PreviousExpiresAt,
+	}, nil
+}
+
+func (s *APIKeyService) RevokeKey(ctx context.Context, id string) (*APIKey, error) {
+	key, err := s.loadKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !key.IsRevoked() {
+		key.RevokedAt = time.Now().Unix()
+		if err := s.saveKey(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+
+	return &key.APIKey, nil
+}
+
+// HasKeys reports whether any API key was ever created.
+func (s *APIKeyService) HasKeys(ctx context.Context) (bool, error) {
+	count, err := s.cache.SCard(ctx, apiKeySetKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to count API keys: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (s *APIKeyService) saveKey(ctx context.Context, key *storedAPIKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode API key: %w", err)
+	}
+
+	if err := s.cache.Set(ctx, apiKeyKey(key.ID), string(data), 0); err != nil {
+		return fmt.Errorf("failed to store API key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *APIKeyService) loadKey(ctx context.Context, id string) (*storedAPIKey, error) {
+	value, err := s.cache.Get(ctx, apiKeyKey(id))
+	if errors.Is(err, cache.ErrKeyNotFound) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up API key: %w", err)
+	}
+
+	var key storedAPIKey
+	if err := json.Unmarshal([]byte(value), &key); err != nil {
+		return nil, fmt.Errorf("failed to decode API key %s: %w", id, err)
+	}
+
+	return &key, nil
+}
+
+func (s *APIKeyService) lastUsed(ctx context.Context, id string) int64 {
+	value, err := s.cache.Get(ctx, apiKeyLastUsedKey(id))
+	if err != nil {
+		return 0
+	}
+
+	ts, _ := strconv.ParseInt(value, 10, 64)
+	return ts
+}
+
+// matches compares secret with the current secret and, until its grace
+// period ends, the one it replaced.
+func (k *storedAPIKey) matches(secret string, now time.Time) bool {
+	hash := []byte(hashAPIKey(secret))
+
+	if subtle.ConstantTimeCompare([]byte(k.Hash), hash) == 1 {
+		return true
+	}
+
+	return k.PreviousHash != "" && now.Unix() < k.PreviousExpiresAt &&
+		subtle.ConstantTimeCompare([]byte(k.PreviousHash), hash) == 1
+}
+
+func newAPIKeySecret(id string) (string, error) {
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate key secret: %w", err)
+	}
+	return apiKeyPrefix + id + "_" + secret, nil
+}
+
+// parseAPIKeyID extracts the hex key ID from a secret of the form
+// "sg_<id>_<random>".
+func parseAPIKeyID(secret string) (string, bool) {
+	rest, ok := strings.CutPrefix(secret, apiKeyPrefix)
+	if !ok {
+		return "", false
+	}
+
+	id, _, ok := strings.Cut(rest, "_")
+	return id, ok && id != ""
+}
+
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func apiKeyKey(id string) string {
+	return "apikey:{" + id + "}"
+}
+
+func apiKeyLastUsedKey(id string) string {
+	return "apikey_used:{" + id + "}"
+}
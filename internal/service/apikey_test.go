@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/william1nguyen/shortygo/internal/config"
+)
+
+func TestCreateKeyValidatesScopes(t *testing.T) {
+	redisCache, _ := newTestCache(t)
+	s := NewAPIKeyService(config.AuthConfig{}, redisCache)
+
+	tests := []struct {
+		name    string
+		scopes  []string
+		wantErr bool
+	}{
+		{name: "known scopes", scopes: []string{ScopeLinksWrite, ScopeStatsRead}},
+		{name: "admin", scopes: []string{ScopeAdmin}},
+		{name: "no scopes", wantErr: true},
+		{name: "unknown scope", scopes: []string{ScopeLinksRead, "links:delete"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.CreateKey(context.Background(), &CreateAPIKeyRequest{Name: "test", Owner: "alice", Scopes: tt.scopes})
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("CreateKey(%v) error = %v, want error %v", tt.scopes, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	redisCache, _ := newTestCache(t)
+	s := NewAPIKeyService(config.AuthConfig{}, redisCache)
+	ctx := context.Background()
+
+	created, err := s.CreateKey(ctx, &CreateAPIKeyRequest{
+		Name:   "billing",
+		Owner:  "alice",
+		Scopes: []string{ScopeLinksWrite},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := s.Authenticate(ctx, created.Secret)
+	if err != nil {
+		t.Fatalf("Authenticate() = %v", err)
+	}
+
+	principal := key.Principal()
+	if principal.KeyID != created.ID || principal.Owner != "alice" {
+		t.Errorf("Principal() = %+v, want key %s of alice", principal, created.ID)
+	}
+	if !principal.HasScope(ScopeLinksWrite) || principal.HasScope(ScopeLinksRead) || principal.HasScope(ScopeAdmin) {
+		t.Errorf("Scopes = %v, want only links:write", principal.Scopes)
+	}
+
+	for _, secret := range []string{
+		created.Secret + "x",
+		"sg_" + created.ID + "_guess",
+		"sg_0000000000000000_guess",
+		"not-a-key",
+	} {
+		if _, err := s.Authenticate(ctx, secret); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("Authenticate(%q) = %v, want ErrInvalidAPIKey", secret, err)
+		}
+	}
+
+	if _, err := s.RevokeKey(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authenticate(ctx, created.Secret); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Authenticate() with a revoked key = %v, want ErrInvalidAPIKey", err)
+	}
+}
+
+func TestRotateKeyGracePeriod(t *testing.T) {
+	redisCache, _ := newTestCache(t)
+	s := NewAPIKeyService(config.AuthConfig{}, redisCache)
+	ctx := context.Background()
+
+	created, err := s.CreateKey(ctx, &CreateAPIKeyRequest{Name: "test", Owner: "alice", Scopes: []string{ScopeLinksRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := s.RotateKey(ctx, created.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("RotateKey() = %v", err)
+	}
+	if rotated.Secret == created.Secret {
+		t.Fatal("RotateKey() kept the old secret")
+	}
+
+	for _, secret := range []string{created.Secret, rotated.Secret} {
+		if _, err := s.Authenticate(ctx, secret); err != nil {
+			t.Errorf("Authenticate() during the grace period = %v, want nil", err)
+		}
+	}
+
+	stored, err := s.loadKey(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := time.Unix(rotated.PreviousExpiresAt, 0)
+	if stored.matches(created.Secret, expired) {
+		t.Error("the old secret still matches once the grace period is over")
+	}
+	if !stored.matches(rotated.Secret, expired) {
+		t.Error("the new secret stopped matching with the grace period")
+	}
+
+	again, err := s.RotateKey(ctx, created.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{created.Secret, rotated.Secret} {
+		if _, err := s.Authenticate(ctx, secret); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("Authenticate() with a secret rotated without grace = %v, want ErrInvalidAPIKey", err)
+		}
+	}
+	if _, err := s.Authenticate(ctx, again.Secret); err != nil {
+		t.Errorf("Authenticate() with the newest secret = %v", err)
+	}
+}
+
+func TestRotateKeyRejectsGracePeriod(t *testing.T) {
+	redisCache, _ := newTestCache(t)
+	s := NewAPIKeyService(config.AuthConfig{}, redisCache)
+	ctx := context.Background()
+
+	created, err := s.CreateKey(ctx, &CreateAPIKeyRequest{Name: "test", Owner: "alice", Scopes: []string{ScopeLinksRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, grace := range []time.Duration{-time.Second, MaxRotationGracePeriod + time.Second} {
+		if _, err := s.RotateKey(ctx, created.ID, grace); err == nil {
+			t.Errorf("RotateKey() accepted a grace period of %v", grace)
+		}
+	}
+
+	if _, err := s.RevokeKey(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RotateKey(ctx, created.ID, time.Hour); err == nil {
+		t.Error("RotateKey() rotated a revoked key")
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		scopes []string
+		scope  string
+		want   bool
+	}{
+		{scopes: []string{ScopeLinksWrite}, scope: ScopeLinksWrite, want: true},
+		{scopes: []string{ScopeLinksWrite}, scope: ScopeStatsRead, want: false},
+		{scopes: []string{ScopeAdmin}, scope: ScopeStatsRead, want: true},
+		{scopes: nil, scope: ScopeLinksRead, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := HasScope(tt.scopes, tt.scope); got != tt.want {
+			t.Errorf("HasScope(%v, %s) = %v, want %v", tt.scopes, tt.scope, got, tt.want)
+		}
+	}
+
+	var nobody *Principal
+	if nobody.HasScope(ScopeLinksRead) {
+		t.Error("a nil principal has scopes")
+	}
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// consumeClickScript takes one click from a max-clicks link. The last
+// click deletes the link and leaves a tombstone, and clicks past the limit
+// get -1, so concurrent visitors can never exceed it. A missing remaining
+// counter also gets -1 without being written, rather than being recreated
+// without a TTL.
+//
+// KEYS: link, remaining clicks, clicks, tombstone. ARGV: tombstone TTL in seconds.
+var consumeClickScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 or redis.call("EXISTS", KEYS[2]) == 0 then
+	return -1
+end
+
+local remaining = redis.call("DECR", KEYS[2])
+if remaining < 0 then
+	return -1
+end
+
+redis.call("INCR", KEYS[3])
+
+if remaining == 0 then
+	redis.call("DEL", KEYS[1], KEYS[2])
+	redis.call("SET", KEYS[4], "exhausted", "EX", ARGV[1])
+end
+
+return remaining
+`)
+
+// RecordClick counts a visit that is about to be redirected, and the
+// variant it was sent to unless that is NoVariant. For links with MaxClicks
+// it also consumes one of the remaining clicks and returns ErrURLGone once
+// they are used up.
+func (s *URLService) RecordClick(ctx context.Context, link *Link, variant int) error {
+	if err := s.consumeClick(ctx, link); err != nil {
+		return err
+	}
+
+	if variant == NoVariant {
+		return nil
+	}
+
+	if _, err := s.cache.HIncrBy(ctx, variantClicksKey(link.ShortID), strconv.Itoa(variant), 1); err != nil {
+		return fmt.Errorf("failed to record variant click: %w", err)
+	}
+
+	return nil
+}
+
+func (s *URLService) consumeClick(ctx context.Context, link *Link) error {
+	if link.MaxClicks <= 0 {
+		if _, err := s.cache.Incr(ctx, clicksKey(link.ShortID)); err != nil {
+			return fmt.Errorf("failed to record click: %w", err)
+		}
+		return nil
+	}
+
+	keys := []string{
+		link.ShortID,
+		remainingClicksKey(link.ShortID),
+		clicksKey(link.ShortID),
+		tombstoneKey(link.ShortID),
+	}
+
+	remaining, err := s.cache.RunScript(ctx, consumeClickScript, keys, int64(TombstoneTTL.Seconds()))
+	if err != nil {
+		return fmt.Errorf("failed to record click: %w", err)
+	}
+
+	if n, ok := remaining.(int64); !ok || n < 0 {
+		return ErrURLGone
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRecordClickConsumesMaxClicks(t *testing.T) {
+	redisCache, server := newTestCache(t)
+	s := &URLService{cache: redisCache}
+	ctx := context.Background()
+
+	link := &Link{ShortID: "abc123", OriginalURL: "https://example.com", MaxClicks: 2}
+	if err := s.saveLink(ctx, link, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Set(remainingClicksKey(link.ShortID), "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 2 {
+		if err := s.RecordClick(ctx, link, NoVariant); err != nil {
+			t.Fatalf("click %d: RecordClick() = %v, want nil", i+1, err)
+		}
+	}
+	if err := s.RecordClick(ctx, link, NoVariant); !errors.Is(err, ErrURLGone) {
+		t.Fatalf("RecordClick() past the limit = %v, want ErrURLGone", err)
+	}
+	if server.Exists(link.ShortID) {
+		t.Error("link still exists after its last click")
+	}
+}
+
+func TestRecordClickFailsClosedWithoutRemainingCounter(t *testing.T) {
+	redisCache, server := newTestCache(t)
+	s := &URLService{cache: redisCache}
+	ctx := context.Background()
+
+	link := &Link{ShortID: "abc123", OriginalURL: "https://example.com", MaxClicks: 2}
+	if err := s.saveLink(ctx, link, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RecordClick(ctx, link, NoVariant); !errors.Is(err, ErrURLGone) {
+		t.Fatalf("RecordClick() = %v, want ErrURLGone", err)
+	}
+	if server.Exists(remainingClicksKey(link.ShortID)) {
+		t.Error("RecordClick() created the remaining clicks counter")
+	}
+	if server.Exists(clicksKey(link.ShortID)) {
+		t.Error("RecordClick() counted a click it did not allow")
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/william1nguyen/shortygo/pkg/utils"
+)
+
+// blockedAppSchemes can run code in the browser and are refused even when
+// configured as app schemes.
+var blockedAppSchemes = []string{"javascript", "data", "vbscript", "file", "blob", "about"}
+
+// DeepLink opens a mobile app instead of the web target. Visitors on iOS
+// and Android get a page that tries AppURL and falls back to FallbackURL,
+// or to the web target the visit would otherwise go to.
+type DeepLink struct {
+	AppURL      string `json:"app_url" binding:"required" example:"myapp://product/42"`
+	FallbackURL string `json:"fallback_url,omitempty" example:"https://example.com/product/42"`
+}
+
+func (s *URLService) normalizeDeepLink(ctx context.Context, deepLink *DeepLink, allowedSchemes []string) error {
+	if deepLink == nil {
+		return nil
+	}
+
+	parsed, err := url.Parse(deepLink.AppURL)
+	if err != nil {
+		return fmt.Errorf("invalid app URL: malformed URL: %w", err)
+	}
+
+	scheme := strings.ToLower(parsed.Scheme)
+	switch {
+	case scheme == "":
+		return fmt.Errorf("invalid app URL: URL must have a scheme")
+	case slices.Contains(blockedAppSchemes, scheme):
+		return fmt.Errorf("invalid app URL: scheme %q is not allowed", scheme)
+	case scheme != "http" && scheme != "https" && !slices.Contains(allowedSchemes, scheme):
+		return fmt.Errorf("invalid app URL: scheme %q is not an allowed app scheme", scheme)
+	}
+
+	// Universal and app links are web URLs, held to the same policy as
+	// every other destination, including the one on links to short links.
+	if scheme == "http" || scheme == "https" {
+		appURL, err := s.normalizeDestination(ctx, deepLink.AppURL)
+		if err != nil {
+			return fmt.Errorf("invalid app URL: %w", err)
+		}
+		deepLink.AppURL = appURL
+	}
+
+	if deepLink.FallbackURL != "" {
+		fallbackURL, err := s.normalizeDestination(ctx, deepLink.FallbackURL)
+		if err != nil {
+			return fmt.Errorf("invalid deep link fallback URL: %w", err)
+		}
+		deepLink.FallbackURL = fallbackURL
+	}
+
+	return nil
+}
+
+// applyDeepLink turns a resolved web target into a deep link for visitors
+// on mobile platforms.
+func (l *Link) applyDeepLink(resolution *Resolution, visitor *Visitor) {
+	if l.DeepLink == nil {
+		return
+	}
+
+	if os := utils.DetectOS(visitor.UserAgent); os != utils.OSIOS && os != utils.OSAndroid {
+		return
+	}
+
+	resolution.AppURL = l.DeepLink.AppURL
+	if l.DeepLink.FallbackURL != "" {
+		resolution.Target = l.DeepLink.FallbackURL
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/william1nguyen/shortygo/internal/cache"
+)
+
+// Link is the record stored under a short ID. Links created before records
+// were introduced are stored as the bare URL and decode with only
+// OriginalURL set.
+type Link struct {
+	ShortID      string            `json:"short_id"`
+	Owner        string            `json:"owner,omitempty"`
+	CreatedBy    string            `json:"created_by,omitempty"`
+	OriginalURL  string            `json:"origin_url"`
+	CreatedAt    int64             `json:"created_at"`
+	ExpiresAt    int64             `json:"expires_at"`
+	Interstitial bool              `json:"interstitial,omitempty"`
+	PasswordHash string            `json:"password_hash,omitempty"`
+	MaxClicks    int               `json:"max_clicks,omitempty"`
+	ActivateAt   int64             `json:"activate_at,omitempty"`
+	FallbackURL  string            `json:"fallback_url,omitempty"`
+	Rules        []RedirectRule    `json:"rules,omitempty"`
+	Destinations []Destination     `json:"destinations,omitempty"`
+	QueryParams  map[string]string `json:"query_params,omitempty"`
+	ForwardQuery bool              `json:"forward_query,omitempty"`
+	ForwardPath  bool              `json:"forward_path,omitempty"`
+	DeepLink     *DeepLink         `json:"deep_link,omitempty"`
+	SocialMeta
+}
+
+func (l *Link) IsProtected() bool {
+	return l.PasswordHash != ""
+}
+
+// IsStable reports whether every visit gets the same redirect for as long as
+// the link lives.
+func (l *Link) IsStable() bool {
+	return l.MaxClicks == 0 && l.ActivateAt == 0 && l.FallbackURL == "" &&
+		len(l.Rules) == 0 && len(l.Destinations) == 0 && !l.ForwardQuery &&
+		l.DeepLink == nil
+}
+
+func (s *URLService) saveLink(ctx context.Context, link *Link, ttl time.Duration) error {
+	data, err := json.Marshal(link)
+	if err != nil {
+		return fmt.Errorf("failed to encode link: %w", err)
+	}
+
+	return s.cache.Set(ctx, link.ShortID, string(data), ttl)
+}
+
+// updateLink rewrites an existing link record, keeping its expiry.
+func (s *URLService) updateLink(ctx context.Context, link *Link) error {
+	data, err := json.Marshal(link)
+	if err != nil {
+		return fmt.Errorf("failed to encode link: %w", err)
+	}
+
+	if err := s.cache.Update(ctx, link.ShortID, string(data)); err != nil {
+		return s.lookupError(ctx, link.ShortID, err)
+	}
+
+	return nil
+}
+
+func (s *URLService) loadLink(ctx context.Context, shortID string) (*Link, error) {
+	if err := s.validateShortID(shortID); err != nil {
+		return nil, fmt.Errorf("invalid short ID: %w: %w", err, ErrURLNotFound)
+	}
+
+	value, err := s.cache.Get(ctx, shortID)
+	if err != nil {
+		return nil, s.lookupError(ctx, shortID, err)
+	}
+
+	if !strings.HasPrefix(value, "{") {
+		return &Link{ShortID: shortID, OriginalURL: value}, nil
+	}
+
+	var link Link
+	if err := json.Unmarshal([]byte(value), &link); err != nil {
+		return nil, fmt.Errorf("failed to decode link %s: %w", shortID, err)
+	}
+
+	return &link, nil
+}
+
+// getCounter reads an integer counter, treating a missing key as zero.
+func (s *URLService) getCounter(ctx context.Context, key string) (int64, error) {
+	value, err := s.cache.Get(ctx, key)
+	if errors.Is(err, cache.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// Keys kept alongside a link carry its ID as a hash tag so they live on
+// the link's shard and can be updated together by a script.
+
+func clicksKey(shortID string) string {
+	return "clicks:{" + shortID + "}"
+}
+
+func variantClicksKey(shortID string) string {
+	return "variants:{" + shortID + "}"
+}
+
+func remainingClicksKey(shortID string) string {
+	return "remaining:{" + shortID + "}"
+}
+
+func tombstoneKey(shortID string) string {
+	return "tombstone:{" + shortID + "}"
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrAuthLocked = errors.New("too many failed authentication attempts")
+
+// forgiveAttemptScript takes back one counted attempt, unless the counter
+// is already gone, so it is never recreated without a TTL.
+var forgiveAttemptScript = redis.NewScript(`
+local value = redis.call("GET", KEYS[1])
+if value and tonumber(value) > 0 then
+	return redis.call("DECR", KEYS[1])
+end
+return 0
+`)
+
+// BeginAuthAttempt counts an authentication attempt of clientIP before its
+// credentials are checked, so concurrent attempts cannot all get in under
+// the limit. It returns ErrAuthLocked once AUTH_MAX_FAILURES attempts are
+// counted within the lockout period, which starts with the first of them.
+// Attempts that authenticate are taken back with ForgiveAuthAttempt.
+func (s *APIKeyService) BeginAuthAttempt(ctx context.Context, clientIP string) error {
+	attempts, err := s.cache.IncrWithTTL(ctx, authFailuresKey(clientIP), s.auth.Lockout)
+	if err != nil {
+		return fmt.Errorf("failed to record authentication attempt: %w", err)
+	}
+
+	if attempts > int64(s.auth.MaxFailures) {
+		return ErrAuthLocked
+	}
+
+	return nil
+}
+
+// ForgiveAuthAttempt takes back an attempt counted by BeginAuthAttempt
+// once it has authenticated.
+func (s *APIKeyService) ForgiveAuthAttempt(ctx context.Context, clientIP string) error {
+	if _, err := s.cache.RunScript(ctx, forgiveAttemptScript, []string{authFailuresKey(clientIP)}); err != nil {
+		return fmt.Errorf("failed to forgive authentication attempt: %w", err)
+	}
+
+	return nil
+}
+
+func authFailuresKey(clientIP string) string {
+	return "authfail:" + clientIP
+}
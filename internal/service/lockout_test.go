@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/william1nguyen/shortygo/internal/config"
+)
+
+func TestAuthAttemptLockout(t *testing.T) {
+	redisCache, server := newTestCache(t)
+	s := NewAPIKeyService(config.AuthConfig{MaxFailures: 3, Lockout: time.Minute}, redisCache)
+	ctx := context.Background()
+
+	for i := range 3 {
+		if err := s.BeginAuthAttempt(ctx, "203.0.113.1"); err != nil {
+			t.Fatalf("attempt %d: BeginAuthAttempt() = %v, want nil", i+1, err)
+		}
+	}
+	if err := s.BeginAuthAttempt(ctx, "203.0.113.1"); !errors.Is(err, ErrAuthLocked) {
+		t.Fatalf("BeginAuthAttempt() past the limit = %v, want ErrAuthLocked", err)
+	}
+	if err := s.BeginAuthAttempt(ctx, "203.0.113.2"); err != nil {
+		t.Fatalf("BeginAuthAttempt() from another IP = %v, want nil", err)
+	}
+
+	server.FastForward(time.Minute)
+	if err := s.BeginAuthAttempt(ctx, "203.0.113.1"); err != nil {
+		t.Fatalf("BeginAuthAttempt() after the lockout expired = %v, want nil", err)
+	}
+}
+
+func TestForgiveAuthAttempt(t *testing.T) {
+	redisCache, server := newTestCache(t)
+	s := NewAPIKeyService(config.AuthConfig{MaxFailures: 2, Lockout: time.Minute}, redisCache)
+	ctx := context.Background()
+
+	for range 5 {
+		if err := s.BeginAuthAttempt(ctx, "203.0.113.1"); err != nil {
+			t.Fatalf("BeginAuthAttempt() = %v, want nil for forgiven attempts", err)
+		}
+		if err := s.ForgiveAuthAttempt(ctx, "203.0.113.1"); err != nil {
+			t.Fatalf("ForgiveAuthAttempt() = %v", err)
+		}
+	}
+
+	if err := s.ForgiveAuthAttempt(ctx, "203.0.113.9"); err != nil {
+		t.Fatalf("ForgiveAuthAttempt() without attempts = %v", err)
+	}
+	if server.Exists(authFailuresKey("203.0.113.9")) {
+		t.Error("ForgiveAuthAttempt() created a counter")
+	}
+}
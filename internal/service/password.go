@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	MaxPasswordLength = 72
+
+	// MaxPasswordAttempts wrong passwords from one IP lock that IP out of
+	// the link for PasswordLockout.
+	MaxPasswordAttempts = 5
+	PasswordLockout     = 15 * time.Minute
+)
+
+var (
+	ErrWrongPassword  = errors.New("wrong password")
+	ErrPasswordLocked = errors.New("too many wrong passwords")
+)
+
+func hashPassword(password string) (string, error) {
+	if len(password) > MaxPasswordLength {
+		return "", fmt.Errorf("password must be at most %d bytes", MaxPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+// VerifyPassword checks a password for a protected link, counting failures
+// per link and client IP so repeated guesses get locked out.
+func (s *URLService) VerifyPassword(ctx context.Context, link *Link, password string, clientIP string) error {
+	key := passwordFailuresKey(link.ShortID, clientIP)
+
+	// Every attempt is counted before the password is compared, so
+	// concurrent guesses cannot all get in under the limit.
+	attempts, err := s.cache.IncrWithTTL(ctx, key, PasswordLockout)
+	if err != nil {
+		return fmt.Errorf("failed to record password attempt: %w", err)
+	}
+	if attempts > MaxPasswordAttempts {
+		return ErrPasswordLocked
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)); err != nil {
+		return ErrWrongPassword
+	}
+
+	if err := s.cache.Delete(ctx, key); err != nil {
+		return fmt.Errorf("failed to reset password failures: %w", err)
+	}
+
+	return nil
+}
+
+func passwordFailuresKey(shortID string, clientIP string) string {
+	return "pwfail:{" + shortID + "}:" + clientIP
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/william1nguyen/shortygo/internal/cache"
+	"github.com/william1nguyen/shortygo/internal/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestCache starts an in-memory Redis for one test.
+func newTestCache(t *testing.T) (*cache.RedisCache, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	redisCache, err := cache.NewRedisCache(config.RedisConfig{Addrs: []string{server.Addr()}})
+	if err != nil {
+		t.Fatalf("NewRedisCache: %v", err)
+	}
+	t.Cleanup(redisCache.Close)
+
+	return redisCache, server
+}
+
+func protectedLink(t *testing.T, password string) *Link {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Link{ShortID: "abc123", PasswordHash: string(hash)}
+}
+
+func TestVerifyPasswordLocksOut(t *testing.T) {
+	redisCache, server := newTestCache(t)
+	s := &URLService{cache: redisCache}
+	link := protectedLink(t, "secret")
+	ctx := context.Background()
+
+	for i := range MaxPasswordAttempts {
+		if err := s.VerifyPassword(ctx, link, "guess", "203.0.113.1"); !errors.Is(err, ErrWrongPassword) {
+			t.Fatalf("attempt %d: VerifyPassword() = %v, want ErrWrongPassword", i+1, err)
+		}
+	}
+
+	if err := s.VerifyPassword(ctx, link, "secret", "203.0.113.1"); !errors.Is(err, ErrPasswordLocked) {
+		t.Fatalf("VerifyPassword() after lockout = %v, want ErrPasswordLocked", err)
+	}
+	if err := s.VerifyPassword(ctx, link, "secret", "203.0.113.2"); err != nil {
+		t.Fatalf("VerifyPassword() from another IP = %v, want nil", err)
+	}
+
+	server.FastForward(PasswordLockout)
+	if err := s.VerifyPassword(ctx, link, "secret", "203.0.113.1"); err != nil {
+		t.Fatalf("VerifyPassword() after the lockout expired = %v, want nil", err)
+	}
+}
+
+func TestVerifyPasswordLocksOutConcurrentGuesses(t *testing.T) {
+	redisCache, _ := newTestCache(t)
+	s := &URLService{cache: redisCache}
+	link := protectedLink(t, "secret")
+
+	const guesses = 4 * MaxPasswordAttempts
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		compared int
+	)
+	for range guesses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.VerifyPassword(context.Background(), link, "guess", "203.0.113.1")
+			if errors.Is(err, ErrWrongPassword) {
+				mu.Lock()
+				compared++
+				mu.Unlock()
+			} else if !errors.Is(err, ErrPasswordLocked) {
+				t.Errorf("VerifyPassword() = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if compared != MaxPasswordAttempts {
+		t.Fatalf("%d of %d concurrent guesses were compared, want %d", compared, guesses, MaxPasswordAttempts)
+	}
+}
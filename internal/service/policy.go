@@ -0,0 +1,278 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/william1nguyen/shortygo/internal/config"
+)
+
+// urlhausURLColumn is the url column of URLhaus CSV dumps:
+// id, dateadded, url, url_status, ...
+const urlhausURLColumn = 2
+
+var ErrDestinationBlocked = errors.New("destination not allowed")
+
+// DestinationPolicy decides which destinations links may point to. Blocks
+// always win over the allowlist.
+type DestinationPolicy struct {
+	blocked  []string
+	allowed  []string
+	patterns []*regexp.Regexp
+	lists    []*threatList
+
+	blockPrivate   bool
+	privateAllowed privateAllowlist
+	resolver       Resolver
+}
+
+// threatList is a blocklist file, swapped out whole when it is reloaded.
+type threatList struct {
+	path string
+
+	mu      sync.RWMutex
+	hosts   map[string]struct{}
+	urls    map[string]struct{}
+	modTime time.Time
+}
+
+// NewDestinationPolicy builds the policy of cfg. resolver looks up the
+// addresses of destination hosts when non-public addresses are blocked.
+func NewDestinationPolicy(cfg config.PolicyConfig, resolver Resolver) (*DestinationPolicy, error) {
+	p := &DestinationPolicy{
+		blocked:        cfg.BlockedDomains,
+		allowed:        cfg.AllowedDomains,
+		blockPrivate:   cfg.BlockPrivate,
+		privateAllowed: parsePrivateAllowlist(cfg.PrivateAllowlist),
+		resolver:       resolver,
+	}
+
+	for _, pattern := range cfg.BlockedPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blocked URL pattern %q: %w", pattern, err)
+		}
+		p.patterns = append(p.patterns, re)
+	}
+
+	for _, path := range cfg.ThreatLists {
+		list := &threatList{path: path}
+		if _, err := list.reload(); err != nil {
+			return nil, err
+		}
+		p.lists = append(p.lists, list)
+	}
+
+	return p, nil
+}
+
+// Check returns ErrDestinationBlocked, with the reason, when u may not be
+// linked to. A nil policy allows everything.
+func (p *DestinationPolicy) Check(u *url.URL) error {
+	if p == nil {
+		return nil
+	}
+
+	host := normalizeHost(u.Hostname())
+	target := normalizeThreatURL(u)
+
+	if domain, ok := matchDomain(host, p.blocked); ok {
+		return fmt.Errorf("%w: domain %s is blocked", ErrDestinationBlocked, domain)
+	}
+
+	for _, list := range p.lists {
+		if reason, ok := list.match(host, target); ok {
+			return fmt.Errorf("%w: %s is on threat list %s", ErrDestinationBlocked, reason, filepath.Base(list.path))
+		}
+	}
+
+	for _, re := range p.patterns {
+		if re.MatchString(u.String()) {
+			return fmt.Errorf("%w: URL matches blocked pattern %s", ErrDestinationBlocked, re)
+		}
+	}
+
+	if len(p.allowed) > 0 {
+		if _, ok := matchDomain(host, p.allowed); !ok {
+			return fmt.Errorf("%w: domain %s is not on the allowlist", ErrDestinationBlocked, host)
+		}
+	}
+
+	if p.blockPrivate {
+		return p.checkAddresses(host)
+	}
+
+	return nil
+}
+
+// Watch reloads threat lists whose files changed, every interval, until
+// ctx is done. A list that fails to load keeps its previous entries.
+func (p *DestinationPolicy) Watch(ctx context.Context, interval time.Duration) {
+	if p == nil || len(p.lists) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, list := range p.lists {
+				reloaded, err := list.reload()
+				if err != nil {
+					log.Printf("Keeping previous threat list: %v", err)
+				} else if reloaded {
+					log.Printf("Reloaded threat list %s", list.path)
+				}
+			}
+		}
+	}
+}
+
+func (l *threatList) match(host string, target string) (string, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if _, ok := l.hosts[host]; ok {
+		return "host " + host, true
+	}
+	if _, ok := l.urls[target]; ok {
+		return "URL " + target, true
+	}
+	return "", false
+}
+
+// reload reads the list again if the file changed since it was last
+// loaded, and reports whether it did.
+func (l *threatList) reload() (bool, error) {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read threat list: %w", err)
+	}
+
+	l.mu.RLock()
+	unchanged := info.ModTime().Equal(l.modTime)
+	l.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read threat list: %w", err)
+	}
+	defer file.Close()
+
+	hosts, urls := map[string]struct{}{}, map[string]struct{}{}
+	if strings.EqualFold(filepath.Ext(l.path), ".csv") {
+		err = parseURLhausCSV(file, urls)
+	} else {
+		err = parseHostsList(file, hosts)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to parse threat list %s: %w", l.path, err)
+	}
+
+	l.mu.Lock()
+	l.hosts, l.urls, l.modTime = hosts, urls, info.ModTime()
+	l.mu.Unlock()
+
+	return true, nil
+}
+
+// parseHostsList reads hosts-format blocklists ("0.0.0.0 evil.example")
+// as well as plain lists of one host per line. Names without a dot, such
+// as localhost, are skipped.
+func parseHostsList(r io.Reader, hosts map[string]struct{}) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if net.ParseIP(fields[0]) != nil {
+			fields = fields[1:]
+		}
+
+		for _, name := range fields {
+			if name = normalizeHost(name); strings.Contains(name, ".") {
+				hosts[name] = struct{}{}
+			}
+		}
+	}
+
+	return scanner.Err()
+}
+
+func parseURLhausCSV(r io.Reader, urls map[string]struct{}) error {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(record) <= urlhausURLColumn {
+			continue
+		}
+
+		u, err := url.Parse(strings.TrimSpace(record[urlhausURLColumn]))
+		if err != nil || u.Host == "" {
+			continue
+		}
+		urls[normalizeThreatURL(u)] = struct{}{}
+	}
+}
+
+// matchDomain finds the domain of domains that host is or is a subdomain of.
+func matchDomain(host string, domains []string) (string, bool) {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return domain, true
+		}
+	}
+	return "", false
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// normalizeThreatURL drops what does not change where a URL points to, so
+// listed URLs match however the destination was written.
+func normalizeThreatURL(u *url.URL) string {
+	normalized := *u
+	normalized.Scheme = strings.ToLower(u.Scheme)
+	normalized.Host = strings.ToLower(strings.TrimSuffix(u.Host, "."))
+	normalized.Fragment = ""
+	normalized.RawFragment = ""
+	if normalized.Path == "" {
+		normalized.Path = "/"
+	}
+	return normalized.String()
+}
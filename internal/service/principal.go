@@ -0,0 +1,55 @@
+package service
+
+import "errors"
+
+var ErrForbidden = errors.New("not allowed to manage this link")
+
+// Principal is the authenticated caller of an API request.
+type Principal struct {
+	KeyID  string
+	Owner  string
+	Scopes []string
+	// RateLimit and DailyQuota carry the limits of the API key, if any.
+	RateLimit  float64
+	DailyQuota int
+	// DefaultQueryParams are the API key's query parameters for new links.
+	DefaultQueryParams map[string]string
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	return p != nil && HasScope(p.Scopes, scope)
+}
+
+// Principal returns the caller authenticated by this key.
+func (k *APIKey) Principal() *Principal {
+	return &Principal{
+		KeyID:      k.ID,
+		Owner:      k.Owner,
+		Scopes:     k.Scopes,
+		RateLimit:  k.RateLimit,
+		DailyQuota: k.DailyQuota,
+
+		DefaultQueryParams: k.DefaultQueryParams,
+	}
+}
+
+func (p *Principal) defaultQueryParams() map[string]string {
+	if p == nil {
+		return nil
+	}
+	return p.DefaultQueryParams
+}
+
+// authorize lets admins manage any link and everyone else only the links
+// they own. Links created before owners were recorded are admin-only.
+func (p *Principal) authorize(link *Link) error {
+	if p.HasScope(ScopeAdmin) {
+		return nil
+	}
+
+	if p == nil || link.Owner == "" || link.Owner != p.Owner {
+		return ErrForbidden
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPrincipalAuthorize(t *testing.T) {
+	alice := &Principal{Owner: "alice", Scopes: []string{ScopeLinksWrite}}
+	bob := &Principal{Owner: "bob", Scopes: []string{ScopeLinksWrite}}
+	admin := &Principal{Owner: "ops", Scopes: []string{ScopeAdmin}}
+
+	tests := []struct {
+		name      string
+		principal *Principal
+		owner     string
+		allowed   bool
+	}{
+		{name: "owner", principal: alice, owner: "alice", allowed: true},
+		{name: "other owner", principal: bob, owner: "alice"},
+		{name: "admin", principal: admin, owner: "alice", allowed: true},
+		{name: "link without owner", principal: alice, owner: ""},
+		{name: "admin on link without owner", principal: admin, owner: "", allowed: true},
+		{name: "principal without owner", principal: &Principal{Scopes: []string{ScopeLinksWrite}}, owner: ""},
+		{name: "no principal", owner: "alice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.principal.authorize(&Link{ShortID: "abc123", Owner: tt.owner})
+			if tt.allowed && err != nil {
+				t.Fatalf("authorize() = %v, want nil", err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrForbidden) {
+				t.Fatalf("authorize() = %v, want ErrForbidden", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/william1nguyen/shortygo/pkg/utils"
+)
+
+type QRCodeRequest struct {
+	Format     string `form:"format,default=png" binding:"oneof=png svg"`
+	Size       int    `form:"size,default=256" binding:"min=64,max=2048"`
+	Level      string `form:"level,default=M" binding:"oneof=L M Q H"`
+	Margin     int    `form:"margin,default=4" binding:"min=0,max=16"`
+	Foreground string `form:"fg,default=000000"`
+	Background string `form:"bg,default=ffffff"`
+}
+
+type QRCode struct {
+	Data        []byte
+	ContentType string
+}
+
+// QRCodeETag identifies the QR code a request would produce without
+// drawing it; the same link and options always give the same image.
+func (s *URLService) QRCodeETag(link *Link, req *QRCodeRequest) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%s|%d|%s|%s",
+		s.shortURL(link.ShortID), req.Format, req.Size, req.Level, req.Margin, req.Foreground, req.Background)))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// RenderQRCode draws a QR code of the full short URL of the link.
+func (s *URLService) RenderQRCode(link *Link, req *QRCodeRequest) (*QRCode, error) {
+	foreground, err := utils.ParseHexColor(req.Foreground)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fg: %w", err)
+	}
+
+	background, err := utils.ParseHexColor(req.Background)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bg: %w", err)
+	}
+
+	opts := utils.QROptions{
+		Size:       req.Size,
+		Level:      req.Level,
+		Margin:     req.Margin,
+		Foreground: foreground,
+		Background: background,
+	}
+
+	if req.Format == "svg" {
+		data, err := utils.EncodeQRSVG(s.shortURL(link.ShortID), opts)
+		if err != nil {
+			return nil, err
+		}
+		return &QRCode{Data: data, ContentType: "image/svg+xml"}, nil
+	}
+
+	data, err := utils.EncodeQRPNG(s.shortURL(link.ShortID), opts)
+	if err != nil {
+		return nil, err
+	}
+	return &QRCode{Data: data, ContentType: "image/png"}, nil
+}
+
+func (s *URLService) shortURL(shortID string) string {
+	return fmt.Sprintf("%s/%s", s.cfg.BaseURL, shortID)
+}
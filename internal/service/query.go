@@ -0,0 +1,104 @@
+package service
+
+import (
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// MaxForwardedPathLength bounds the path forwarded by prefix-style links.
+const MaxForwardedPathLength = 1024
+
+// reservedQueryParams control the short link itself and are never
+// forwarded to the target.
+var reservedQueryParams = []string{"preview", "continue", HopsQueryParam}
+
+// appendQuery adds the link's query parameters and, for links forwarding
+// the visitor's query, the visitor's parameters to target. Parameters
+// already on the target win over the link's, which win over the visitor's.
+func (l *Link) appendQuery(target string, visitorQuery url.Values) string {
+	if len(l.QueryParams) == 0 && (!l.ForwardQuery || len(visitorQuery) == 0) {
+		return target
+	}
+
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+
+	existing := parsed.Query()
+	extra := url.Values{}
+
+	for name, value := range l.QueryParams {
+		if !existing.Has(name) {
+			extra.Set(name, value)
+		}
+	}
+
+	if l.ForwardQuery {
+		for name, values := range visitorQuery {
+			if existing.Has(name) || extra.Has(name) || slices.Contains(reservedQueryParams, name) {
+				continue
+			}
+			extra[name] = values
+		}
+	}
+
+	if len(extra) == 0 {
+		return target
+	}
+
+	if parsed.RawQuery == "" {
+		parsed.RawQuery = extra.Encode()
+	} else {
+		parsed.RawQuery += "&" + extra.Encode()
+	}
+
+	return parsed.String()
+}
+
+// mergeQueryParams returns the link's query parameters on top of the API
+// key's defaults, on top of the configured defaults.
+func mergeQueryParams(defaults url.Values, keyDefaults map[string]string, params map[string]string) map[string]string {
+	if len(defaults) == 0 && len(keyDefaults) == 0 {
+		return params
+	}
+
+	merged := make(map[string]string, len(defaults)+len(keyDefaults)+len(params))
+	for name := range defaults {
+		merged[name] = defaults.Get(name)
+	}
+	for name, value := range keyDefaults {
+		merged[name] = value
+	}
+	for name, value := range params {
+		merged[name] = value
+	}
+
+	return merged
+}
+
+// joinPath appends rest to the path of target. rest is cleaned first, so
+// "." and ".." segments cannot climb out of the target path, and only the
+// path is rewritten, so the host can never change.
+func joinPath(target string, rest string) string {
+	if rest == "" || rest == "/" || len(rest) > MaxForwardedPathLength {
+		return target
+	}
+
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+
+	cleaned := path.Clean("/" + strings.ReplaceAll(rest, "\\", "/"))
+	if strings.HasSuffix(rest, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/") + cleaned
+	parsed.RawPath = ""
+
+	return parsed.String()
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/william1nguyen/shortygo/internal/cache"
+)
+
+// gcraScript is the generic cell rate algorithm: the key holds the
+// theoretical arrival time (TAT) of the next request in microseconds, and
+// a request is allowed while the TAT is at most a burst ahead of now.
+// Redis time is used so every instance shares one clock.
+//
+// KEYS: limiter. ARGV: emission interval and burst, both in microseconds.
+// Returns allowed (0 or 1), remaining requests and retry-after in
+// microseconds.
+var gcraScript = redis.NewScript(`
+local now = redis.call("TIME")
+now = tonumber(now[1]) * 1000000 + tonumber(now[2])
+
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+
+local newTAT = tat + interval
+local allowAt = newTAT - burst
+if now < allowAt then
+	return {0, 0, allowAt - now}
+end
+
+redis.call("SET", KEYS[1], string.format("%d", newTAT), "PX", math.ceil((newTAT - now) / 1000))
+return {1, math.floor((now - allowAt) / interval), 0}
+`)
+
+// quotaScript counts a request against a quota unless it is used up, so
+// clients retrying after hitting it do not keep raising their count.
+//
+// KEYS: quota counter. ARGV: quota and counter TTL in milliseconds.
+// Returns allowed (0 or 1) and the requests counted so far.
+var quotaScript = redis.NewScript(`
+local used = tonumber(redis.call("GET", KEYS[1]) or "0")
+if used >= tonumber(ARGV[1]) then
+	return {0, used}
+end
+
+used = redis.call("INCR", KEYS[1])
+if used == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return {1, used}
+`)
+
+// RateLimitPolicy is a token bucket refilled at RequestsPerSecond and
+// holding up to Burst requests. Name separates the buckets of policies
+// applied to the same client.
+type RateLimitPolicy struct {
+	Name              string
+	RequestsPerSecond float64
+	Burst             int
+}
+
+// NewRateLimitPolicy allows bursts of two seconds worth of requests.
+func NewRateLimitPolicy(name string, requestsPerSecond float64) RateLimitPolicy {
+	return RateLimitPolicy{
+		Name:              name,
+		RequestsPerSecond: requestsPerSecond,
+		Burst:             max(1, int(requestsPerSecond*2)),
+	}
+}
+
+// RateLimitResult is the outcome of counting one request against a limit.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long a rejected client has to wait.
+	RetryAfter time.Duration
+}
+
+// RateLimitService enforces rate limits shared by every instance through
+// Redis.
+type RateLimitService struct {
+	cache *cache.RedisCache
+}
+
+func NewRateLimitService(cache *cache.RedisCache) *RateLimitService {
+	return &RateLimitService{cache: cache}
+}
+
+// Allow counts a request of key against policy.
+func (s *RateLimitService) Allow(ctx context.Context, key string, policy RateLimitPolicy) (*RateLimitResult, error) {
+	interval := time.Duration(float64(time.Second) / policy.RequestsPerSecond)
+
+	value, err := s.cache.RunScript(ctx, gcraScript, []string{rateLimitKey(policy.Name, key)},
+		interval.Microseconds(), interval.Microseconds()*int64(policy.Burst))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+
+	reply, ok := value.([]interface{})
+	if !ok || len(reply) != 3 {
+		return nil, fmt.Errorf("unexpected rate limit reply %v", value)
+	}
+
+	allowed, _ := reply[0].(int64)
+	remaining, _ := reply[1].(int64)
+	retryAfter, _ := reply[2].(int64)
+
+	return &RateLimitResult{
+		Allowed:    allowed == 1,
+		Limit:      policy.Burst,
+		Remaining:  int(remaining),
+		RetryAfter: time.Duration(retryAfter) * time.Microsecond,
+	}, nil
+}
+
+// ConsumeQuota counts a request of an API key against its daily quota. Days
+// start at midnight UTC.
+func (s *RateLimitService) ConsumeQuota(ctx context.Context, keyID string, quota int) (*RateLimitResult, error) {
+	now := time.Now().UTC()
+	key := quotaKey(keyID, now)
+
+	// The day is part of the key; the expiry only cleans up.
+	value, err := s.cache.RunScript(ctx, quotaScript, []string{key}, quota, (48 * time.Hour).Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to count quota: %w", err)
+	}
+
+	reply, ok := value.([]interface{})
+	if !ok || len(reply) != 2 {
+		return nil, fmt.Errorf("unexpected quota reply %v", value)
+	}
+
+	allowed, _ := reply[0].(int64)
+	used, _ := reply[1].(int64)
+
+	result := &RateLimitResult{
+		Allowed:   allowed == 1,
+		Limit:     quota,
+		Remaining: max(0, quota-int(used)),
+	}
+	if !result.Allowed {
+		result.RetryAfter = now.Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(now)
+	}
+
+	return result, nil
+}
+
+func rateLimitKey(policy string, key string) string {
+	return "ratelimit:{" + policy + ":" + key + "}"
+}
+
+func quotaKey(keyID string, day time.Time) string {
+	return "quota:{" + keyID + "}:" + day.Format("20060102")
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestConsumeQuota(t *testing.T) {
+	redisCache, server := newTestCache(t)
+	s := NewRateLimitService(redisCache)
+	ctx := context.Background()
+
+	for i := range 3 {
+		result, err := s.ConsumeQuota(ctx, "key1", 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed || result.Remaining != 2-i {
+			t.Fatalf("request %d: Allowed = %v, Remaining = %d, want true, %d", i+1, result.Allowed, result.Remaining, 2-i)
+		}
+	}
+
+	for range 5 {
+		result, err := s.ConsumeQuota(ctx, "key1", 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Allowed || result.Remaining != 0 || result.RetryAfter <= 0 {
+			t.Fatalf("request past the quota: %+v, want rejected with a retry", result)
+		}
+	}
+
+	key := quotaKey("key1", time.Now().UTC())
+	used, err := server.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used != "3" {
+		t.Errorf("quota counter = %s, want 3: rejected requests must not be counted", used)
+	}
+	if server.TTL(key) <= 0 {
+		t.Error("quota counter has no TTL")
+	}
+}
+
+func TestAllowBurst(t *testing.T) {
+	redisCache, _ := newTestCache(t)
+	s := NewRateLimitService(redisCache)
+	policy := NewRateLimitPolicy("test", 1)
+
+	for i := range policy.Burst {
+		result, err := s.Allow(context.Background(), "client", policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed {
+			t.Fatalf("request %d of a burst of %d was rejected", i+1, policy.Burst)
+		}
+	}
+
+	result, err := s.Allow(context.Background(), "client", policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed || result.RetryAfter <= 0 {
+		t.Fatalf("request past the burst: %+v, want rejected with a retry", result)
+	}
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/william1nguyen/shortygo/pkg/utils"
+)
+
+const MaxRedirectRules = 20
+
+// RedirectRule sends visitors matching all of its conditions to Target.
+// Rules are evaluated in order and the first match wins; within a
+// condition, any listed value matches.
+type RedirectRule struct {
+	Devices   []string `json:"devices,omitempty" example:"mobile"`
+	OS        []string `json:"os,omitempty" example:"ios"`
+	Languages []string `json:"languages,omitempty" example:"en"`
+	Countries []string `json:"countries,omitempty" example:"US"`
+	// TimeFrom and TimeTo bound the UTC time of day as "HH:MM"; a range
+	// may wrap past midnight.
+	TimeFrom string `json:"time_from,omitempty" example:"09:00"`
+	TimeTo   string `json:"time_to,omitempty" example:"17:00"`
+	// Query requires each parameter to be present with the given value,
+	// or just present when the value is empty.
+	Query  map[string]string `json:"query,omitempty"`
+	Target string            `json:"target" binding:"required"`
+}
+
+type RulesRequest struct {
+	Rules []RedirectRule `json:"rules"`
+}
+
+type RulesResponse struct {
+	ShortID string         `json:"short_id"`
+	Rules   []RedirectRule `json:"rules"`
+}
+
+// Visitor holds the request attributes redirect rules can match on.
+type Visitor struct {
+	UserAgent      string
+	AcceptLanguage string
+	Country        string
+	Query          url.Values
+	// Path is the part of the request path after the short ID, forwarded
+	// by links with ForwardPath.
+	Path string
+	Time time.Time
+	// Variant is the A/B variant the visitor was assigned on an earlier
+	// visit, or NoVariant.
+	Variant int
+	// Hops counts the redirects between our own short links that led the
+	// visitor here.
+	Hops int
+}
+
+var (
+	ruleDevices = []string{utils.DeviceDesktop, utils.DeviceMobile, utils.DeviceTablet, utils.DeviceBot}
+	ruleOS      = []string{utils.OSIOS, utils.OSAndroid, utils.OSWindows, utils.OSMacOS, utils.OSLinux, utils.OSOther}
+)
+
+// ResolveTarget returns the target of the first rule matching the visitor.
+// When none does, it picks one of the link's A/B destinations, or falls back
+// to the original URL. The forwarded path and the link's query parameters
+// are added either way, and mobile visitors of deep links get the app URL.
+// Targets on our own domains count the hop, so redirect loops end.
+func (s *URLService) ResolveTarget(link *Link, visitor *Visitor) *Resolution {
+	resolution := link.resolve(visitor)
+	if link.ForwardPath {
+		resolution.Target = joinPath(resolution.Target, visitor.Path)
+	}
+	resolution.Target = link.appendQuery(resolution.Target, visitor.Query)
+	resolution.Target = s.markHop(resolution.Target, visitor.Hops)
+	link.applyDeepLink(resolution, visitor)
+	return resolution
+}
+
+func (l *Link) resolve(visitor *Visitor) *Resolution {
+	for _, rule := range l.Rules {
+		if rule.matches(visitor) {
+			return &Resolution{Target: rule.Target, Variant: NoVariant}
+		}
+	}
+
+	if len(l.Destinations) > 0 {
+		variant := l.chooseVariant(visitor.Variant)
+		return &Resolution{Target: l.Destinations[variant].URL, Variant: variant}
+	}
+
+	return &Resolution{Target: l.OriginalURL, Variant: NoVariant}
+}
+
+// UpdateRules replaces the redirect rules of a link.
+func (s *URLService) UpdateRules(ctx context.Context, shortID string, rules []RedirectRule, principal *Principal) (*Link, error) {
+	link, err := s.GetManagedLink(ctx, shortID, principal)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.normalizeRules(ctx, rules); err != nil {
+		return nil, err
+	}
+
+	link.Rules = rules
+	if err := s.updateLink(ctx, link); err != nil {
+		return nil, err
+	}
+
+	return link, nil
+}
+
+func (s *URLService) normalizeRules(ctx context.Context, rules []RedirectRule) error {
+	if len(rules) > MaxRedirectRules {
+		return fmt.Errorf("at most %d rules are allowed", MaxRedirectRules)
+	}
+
+	for i := range rules {
+		if err := s.normalizeRule(ctx, &rules[i]); err != nil {
+			return fmt.Errorf("invalid rule %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *URLService) normalizeRule(ctx context.Context, rule *RedirectRule) error {
+	target, err := s.normalizeDestination(ctx, rule.Target)
+	if err != nil {
+		return fmt.Errorf("invalid target: %w", err)
+	}
+	rule.Target = target
+
+	for i, device := range rule.Devices {
+		rule.Devices[i] = strings.ToLower(device)
+		if !slices.Contains(ruleDevices, rule.Devices[i]) {
+			return fmt.Errorf("unknown device %q", device)
+		}
+	}
+
+	for i, os := range rule.OS {
+		rule.OS[i] = strings.ToLower(os)
+		if !slices.Contains(ruleOS, rule.OS[i]) {
+			return fmt.Errorf("unknown os %q", os)
+		}
+	}
+
+	for i, lang := range rule.Languages {
+		rule.Languages[i] = strings.ToLower(lang)
+	}
+
+	for i, country := range rule.Countries {
+		if len(country) != 2 {
+			return fmt.Errorf("country %q must be a two-letter ISO code", country)
+		}
+		rule.Countries[i] = strings.ToUpper(country)
+	}
+
+	if (rule.TimeFrom == "") != (rule.TimeTo == "") {
+		return fmt.Errorf("time_from and time_to must be set together")
+	}
+	if rule.TimeFrom != "" {
+		if _, err := parseTimeOfDay(rule.TimeFrom); err != nil {
+			return err
+		}
+		if _, err := parseTimeOfDay(rule.TimeTo); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *RedirectRule) matches(v *Visitor) bool {
+	if len(r.Devices) > 0 && !slices.Contains(r.Devices, utils.DetectDevice(v.UserAgent)) {
+		return false
+	}
+
+	if len(r.OS) > 0 && !slices.Contains(r.OS, utils.DetectOS(v.UserAgent)) {
+		return false
+	}
+
+	if len(r.Languages) > 0 && !matchLanguage(r.Languages, v.AcceptLanguage) {
+		return false
+	}
+
+	if len(r.Countries) > 0 && !slices.Contains(r.Countries, strings.ToUpper(v.Country)) {
+		return false
+	}
+
+	if r.TimeFrom != "" && !matchTimeOfDay(r.TimeFrom, r.TimeTo, v.Time) {
+		return false
+	}
+
+	for name, value := range r.Query {
+		if !v.Query.Has(name) || (value != "" && v.Query.Get(name) != value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchLanguage reports whether any language the visitor accepts equals or
+// is a subtag of one of the wanted languages, so "en" matches "en-GB".
+func matchLanguage(wanted []string, acceptLanguage string) bool {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(params) == "q=0" {
+			continue
+		}
+
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		for _, lang := range wanted {
+			if tag == lang || strings.HasPrefix(tag, lang+"-") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchTimeOfDay(from string, to string, now time.Time) bool {
+	start, err := parseTimeOfDay(from)
+	if err != nil {
+		return false
+	}
+	end, err := parseTimeOfDay(to)
+	if err != nil {
+		return false
+	}
+
+	utc := now.UTC()
+	minute := utc.Hour()*60 + utc.Minute()
+
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// parseTimeOfDay parses "HH:MM" into minutes since midnight.
+func parseTimeOfDay(s string) (int, error) {
+	hours, minutes, ok := strings.Cut(s, ":")
+	h, herr := strconv.Atoi(hours)
+	m, merr := strconv.Atoi(minutes)
+	if !ok || herr != nil || merr != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return h*60 + m, nil
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/william1nguyen/shortygo/internal/config"
+)
+
+const (
+	// MaxRedirectHops bounds chains of short links, both when resolving the
+	// target of a new link and when visitors are sent from one of our links
+	// to another.
+	MaxRedirectHops = 5
+
+	// HopsQueryParam counts the redirects a visitor took between our own
+	// short links.
+	HopsQueryParam = "sg_hops"
+
+	SelfLinksResolve = "resolve"
+	SelfLinksReject  = "reject"
+
+	shortenerTimeout = 5 * time.Second
+)
+
+var ErrRedirectLoop = errors.New("too many redirects between short links")
+
+// routePrefixes are the first path segments of our routes other than
+// short links.
+var routePrefixes = []string{"api", "health", "swagger"}
+
+// normalizeDestination normalizes and validates a URL visitors may be sent
+// to, resolving or rejecting it when it is a short link itself.
+func (s *URLService) normalizeDestination(ctx context.Context, rawURL string) (string, error) {
+	target, err := s.normalizeURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.validateURL(target); err != nil {
+		return "", err
+	}
+
+	return s.resolveSelfLinks(ctx, target)
+}
+
+// resolveSelfLinks replaces a target on one of our own domains or another
+// known shortener with the destination it leads to, or rejects it when
+// SELF_LINKS is "reject". Every resolved target is validated again.
+func (s *URLService) resolveSelfLinks(ctx context.Context, target string) (string, error) {
+	for range MaxRedirectHops {
+		u, err := url.Parse(target)
+		if err != nil {
+			return "", fmt.Errorf("malformed URL: %w", err)
+		}
+
+		host := normalizeHost(u.Hostname())
+		own := isOwnHost(host, s.cfg)
+		if _, shortener := matchDomain(host, s.cfg.Links.Shorteners); !own && !shortener {
+			return target, nil
+		}
+
+		// Our other pages, such as the API docs, cannot redirect.
+		if _, _, ok := s.shortLinkPath(u.Path); own && !ok {
+			return target, nil
+		}
+
+		if s.cfg.Links.SelfLinks == SelfLinksReject {
+			return "", fmt.Errorf("links to short URLs on %s are not allowed, link to the destination instead", host)
+		}
+
+		if own {
+			target, err = s.ownLinkTarget(ctx, u)
+		} else {
+			target, err = followShortener(ctx, u)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if err := s.validateURL(target); err != nil {
+			return "", fmt.Errorf("short URL %s leads to %s: %w", u, target, err)
+		}
+	}
+
+	return "", ErrRedirectLoop
+}
+
+// ownLinkTarget is the destination of one of our own short links. Only
+// links that always send visitors to the same place can be resolved.
+func (s *URLService) ownLinkTarget(ctx context.Context, u *url.URL) (string, error) {
+	shortID, rest, ok := s.shortLinkPath(u.Path)
+	if !ok || rest != "" {
+		return "", fmt.Errorf("%s is not a short link", u)
+	}
+
+	link, err := s.GetLink(ctx, shortID)
+	if err != nil {
+		return "", fmt.Errorf("short link %s: %w", shortID, err)
+	}
+
+	if !link.IsStable() || link.IsProtected() || link.Interstitial {
+		return "", fmt.Errorf("short link %s cannot be resolved, link to its destination instead", shortID)
+	}
+
+	return link.appendQuery(link.OriginalURL, nil), nil
+}
+
+// followShortener asks another shortener where u redirects to, one hop
+// at a time.
+func followShortener(ctx context.Context, u *url.URL) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, shortenerTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", u, err)
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", u, err)
+	}
+	resp.Body.Close()
+
+	location, err := resp.Location()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: it answered %s without a redirect", u, resp.Status)
+	}
+
+	return location.String(), nil
+}
+
+// markHop counts a redirect to one of our own short links on target, so
+// that loops created after the links were made still end.
+func (s *URLService) markHop(target string, hops int) string {
+	u, err := url.Parse(target)
+	if err != nil || !isOwnHost(normalizeHost(u.Hostname()), s.cfg) {
+		return target
+	}
+	if _, _, ok := s.shortLinkPath(u.Path); !ok {
+		return target
+	}
+
+	query := u.Query()
+	query.Set(HopsQueryParam, strconv.Itoa(hops+1))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// shortLinkPath splits a path on our own domains into the short ID, with
+// any preview "+" dropped, and the forwarded rest of the path. ok is false
+// for paths of other routes.
+func (s *URLService) shortLinkPath(path string) (shortID string, rest string, ok bool) {
+	shortID, rest, _ = strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if slices.Contains(routePrefixes, shortID) {
+		return "", "", false
+	}
+
+	shortID = strings.TrimSuffix(shortID, "+")
+	if s.validateShortID(shortID) != nil {
+		return "", "", false
+	}
+
+	return shortID, rest, true
+}
+
+func isOwnHost(host string, cfg *config.Config) bool {
+	if base, err := url.Parse(cfg.BaseURL); err == nil && base.Hostname() != "" &&
+		host == normalizeHost(base.Hostname()) {
+		return true
+	}
+	return slices.Contains(cfg.Links.OwnDomains, host)
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/william1nguyen/shortygo/internal/config"
+)
+
+func TestNormalizeDestinationSelfLinks(t *testing.T) {
+	redisCache, _ := newTestCache(t)
+	ctx := context.Background()
+
+	newService := func(selfLinks string) *URLService {
+		return NewURLService(&config.Config{
+			BaseURL: "https://sho.rt",
+			Links:   config.LinkConfig{SelfLinks: selfLinks},
+		}, redisCache, nil)
+	}
+
+	resolve := newService(SelfLinksResolve)
+	links := []*Link{
+		{ShortID: "stable", OriginalURL: "https://example.com/page"},
+		{ShortID: "chained", OriginalURL: "https://sho.rt/stable"},
+		{ShortID: "limited", OriginalURL: "https://example.com/page", MaxClicks: 3},
+		{ShortID: "loopa", OriginalURL: "https://sho.rt/loopb"},
+		{ShortID: "loopb", OriginalURL: "https://sho.rt/loopa"},
+	}
+	for _, link := range links {
+		if err := resolve.saveLink(ctx, link, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		selfLinks string
+		url       string
+		want      string
+		wantErr   bool
+	}{
+		{name: "other host", selfLinks: SelfLinksResolve, url: "https://example.org/a", want: "https://example.org/a"},
+		{name: "own route", selfLinks: SelfLinksResolve, url: "https://sho.rt/swagger/index.html", want: "https://sho.rt/swagger/index.html"},
+		{name: "own link", selfLinks: SelfLinksResolve, url: "https://sho.rt/stable", want: "https://example.com/page"},
+		{name: "chain of own links", selfLinks: SelfLinksResolve, url: "https://sho.rt/chained", want: "https://example.com/page"},
+		{name: "unstable own link", selfLinks: SelfLinksResolve, url: "https://sho.rt/limited", wantErr: true},
+		{name: "loop", selfLinks: SelfLinksResolve, url: "https://sho.rt/loopa", wantErr: true},
+		{name: "rejected own link", selfLinks: SelfLinksReject, url: "https://sho.rt/stable", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newService(tt.selfLinks).normalizeDestination(ctx, tt.url)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("normalizeDestination(%s) = %s, want an error", tt.url, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("normalizeDestination(%s) error = %v", tt.url, err)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeDestination(%s) = %s, want %s", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeDeepLinkSelfLinks(t *testing.T) {
+	redisCache, _ := newTestCache(t)
+	ctx := context.Background()
+
+	s := NewURLService(&config.Config{
+		BaseURL: "https://sho.rt",
+		Links:   config.LinkConfig{SelfLinks: SelfLinksResolve},
+	}, redisCache, nil)
+	links := []*Link{
+		{ShortID: "stable", OriginalURL: "https://example.com/page"},
+		{ShortID: "limited", OriginalURL: "https://example.com/page", MaxClicks: 3},
+	}
+	for _, link := range links {
+		if err := s.saveLink(ctx, link, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deepLink := &DeepLink{AppURL: "https://sho.rt/stable"}
+	if err := s.normalizeDeepLink(ctx, deepLink, nil); err != nil {
+		t.Fatalf("normalizeDeepLink() error = %v", err)
+	}
+	if deepLink.AppURL != "https://example.com/page" {
+		t.Errorf("AppURL = %s, want https://example.com/page", deepLink.AppURL)
+	}
+
+	if err := s.normalizeDeepLink(ctx, &DeepLink{AppURL: "https://sho.rt/limited"}, nil); err == nil {
+		t.Error("normalizeDeepLink() accepted an app URL leading to an unstable short link")
+	}
+}
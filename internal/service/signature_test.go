@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/william1nguyen/shortygo/internal/config"
+)
+
+func TestVerifySignature(t *testing.T) {
+	redisCache, server := newTestCache(t)
+	s := NewAPIKeyService(config.AuthConfig{SigningSecret: "server-secret"}, redisCache)
+	ctx := context.Background()
+	const maxSkew = 5 * time.Minute
+
+	created, err := s.CreateKey(ctx, &CreateAPIKeyRequest{Name: "test", Owner: "alice", Scopes: []string{ScopeLinksWrite}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.SigningSecret == "" {
+		t.Fatal("CreateKey() issued no signing secret")
+	}
+
+	now := time.Now()
+	signed := func(signingSecret string, mutate func(*SignedRequest)) *SignedRequest {
+		req := &SignedRequest{
+			KeyID:      created.ID,
+			Method:     "POST",
+			Path:       "/api/v1/shorten",
+			Timestamp:  now.Unix(),
+			BodySHA256: strings.Repeat("ab", 32),
+		}
+		if mutate != nil {
+			mutate(req)
+		}
+		req.Signature = SignRequest(signingSecret, req)
+		return req
+	}
+
+	tests := []struct {
+		name    string
+		req     *SignedRequest
+		wantErr error
+	}{
+		{name: "valid", req: signed(created.SigningSecret, func(r *SignedRequest) { r.Nonce = "n1" })},
+		{
+			name:    "replayed nonce",
+			req:     signed(created.SigningSecret, func(r *SignedRequest) { r.Nonce = "n1" }),
+			wantErr: ErrReplayedRequest,
+		},
+		{
+			name: "within the skew",
+			req: signed(created.SigningSecret, func(r *SignedRequest) {
+				r.Nonce, r.Timestamp = "n2", now.Add(-maxSkew+time.Minute).Unix()
+			}),
+		},
+		{
+			name: "too old",
+			req: signed(created.SigningSecret, func(r *SignedRequest) {
+				r.Nonce, r.Timestamp = "n3", now.Add(-maxSkew-time.Minute).Unix()
+			}),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "too far ahead",
+			req: signed(created.SigningSecret, func(r *SignedRequest) {
+				r.Nonce, r.Timestamp = "n4", now.Add(maxSkew+time.Minute).Unix()
+			}),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "signed with the stored hash",
+			req:     signed(hashAPIKey(created.Secret), func(r *SignedRequest) { r.Nonce = "n5" }),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "tampered path",
+			req: func() *SignedRequest {
+				req := signed(created.SigningSecret, func(r *SignedRequest) { r.Nonce = "n6" })
+				req.Path = "/api/v1/keys"
+				return req
+			}(),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "missing nonce",
+			req:     signed(created.SigningSecret, func(r *SignedRequest) { r.Nonce = "" }),
+			wantErr: ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := s.VerifySignature(ctx, tt.req, maxSkew)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("VerifySignature() = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifySignature() = %v", err)
+			}
+			if key.ID != created.ID {
+				t.Errorf("key ID = %s, want %s", key.ID, created.ID)
+			}
+		})
+	}
+
+	// A forged request must not use up the nonce of the real one.
+	forged := signed("guess", func(r *SignedRequest) { r.Nonce = "n7" })
+	if _, err := s.VerifySignature(ctx, forged, maxSkew); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("VerifySignature() of a forged request = %v, want ErrInvalidSignature", err)
+	}
+	if _, err := s.VerifySignature(ctx, signed(created.SigningSecret, func(r *SignedRequest) { r.Nonce = "n7" }), maxSkew); err != nil {
+		t.Fatalf("VerifySignature() after a forged request with the same nonce = %v", err)
+	}
+
+	if ttl := server.TTL(signatureNonceKey(created.ID, "n1")); ttl <= 0 || ttl > 2*maxSkew {
+		t.Errorf("nonce TTL = %v, want at most %v", ttl, 2*maxSkew)
+	}
+}
+
+func TestVerifySignatureAfterRotation(t *testing.T) {
+	redisCache, _ := newTestCache(t)
+	s := NewAPIKeyService(config.AuthConfig{SigningSecret: "server-secret"}, redisCache)
+	ctx := context.Background()
+
+	created, err := s.CreateKey(ctx, &CreateAPIKeyRequest{Name: "test", Owner: "alice", Scopes: []string{ScopeLinksWrite}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := s.RotateKey(ctx, created.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(signingSecret string, nonce string) *SignedRequest {
+		req := &SignedRequest{KeyID: created.ID, Method: "GET", Path: "/api/v1/metrics", Timestamp: time.Now().Unix(), Nonce: nonce}
+		req.Signature = SignRequest(signingSecret, req)
+		return req
+	}
+
+	if _, err := s.VerifySignature(ctx, sign(rotated.SigningSecret, "new"), time.Minute); err != nil {
+		t.Errorf("VerifySignature() with the new secret = %v", err)
+	}
+	if _, err := s.VerifySignature(ctx, sign(created.SigningSecret, "old"), time.Minute); err != nil {
+		t.Errorf("VerifySignature() with the old secret during the grace period = %v", err)
+	}
+
+	if _, err := s.RotateKey(ctx, created.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.VerifySignature(ctx, sign(rotated.SigningSecret, "cut"), time.Minute); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifySignature() with a secret rotated without grace = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestVerifySignatureDisabled(t *testing.T) {
+	redisCache, _ := newTestCache(t)
+	s := NewAPIKeyService(config.AuthConfig{}, redisCache)
+
+	req := &SignedRequest{KeyID: "0000000000000000", Method: "GET", Path: "/", Timestamp: time.Now().Unix(), Nonce: "n"}
+	req.Signature = SignRequest("", req)
+	if _, err := s.VerifySignature(context.Background(), req, time.Minute); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("VerifySignature() without SIGNATURE_SECRET = %v, want ErrInvalidSignature", err)
+	}
+}
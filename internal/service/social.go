@@ -0,0 +1,46 @@
+package service
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+const (
+	MaxTitleLength       = 200
+	MaxDescriptionLength = 500
+)
+
+// SocialMeta is what chat apps and social networks show when the short
+// link is pasted.
+type SocialMeta struct {
+	Title       string `json:"title,omitempty" example:"Fall sale"`
+	Description string `json:"description,omitempty" example:"Everything 30% off this week"`
+	ImageURL    string `json:"image_url,omitempty" example:"https://example.com/sale.png"`
+}
+
+func (m *SocialMeta) IsEmpty() bool {
+	return m.Title == "" && m.Description == "" && m.ImageURL == ""
+}
+
+func (s *URLService) normalizeSocialMeta(meta *SocialMeta) error {
+	if utf8.RuneCountInString(meta.Title) > MaxTitleLength {
+		return fmt.Errorf("title must be at most %d characters", MaxTitleLength)
+	}
+
+	if utf8.RuneCountInString(meta.Description) > MaxDescriptionLength {
+		return fmt.Errorf("description must be at most %d characters", MaxDescriptionLength)
+	}
+
+	if meta.ImageURL != "" {
+		imageURL, err := s.normalizeURL(meta.ImageURL)
+		if err != nil {
+			return fmt.Errorf("invalid image URL: %w", err)
+		}
+		if err := s.validateURL(imageURL); err != nil {
+			return fmt.Errorf("invalid image URL: %w", err)
+		}
+		meta.ImageURL = imageURL
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"time"
+)
+
+const (
+	MaxDestinations = 10
+	// MaxWeight caps each destination's weight, so the total of a split
+	// stays far from overflowing.
+	MaxWeight = 10000
+
+	// NoVariant marks a visit that did not go through an A/B split.
+	NoVariant = -1
+)
+
+// Destination is one weighted target of an A/B split.
+type Destination struct {
+	URL    string `json:"url" binding:"required" example:"https://example.com/b"`
+	Weight int    `json:"weight" example:"50"`
+}
+
+type VariantStats struct {
+	Variant int    `json:"variant"`
+	URL     string `json:"url"`
+	Weight  int    `json:"weight"`
+	Clicks  int64  `json:"clicks"`
+}
+
+// Resolution is where a visit goes. Variant is the index of the chosen
+// destination, or NoVariant when the link has no split or a rule matched.
+// AppURL is set when the visitor should first be offered the app, with
+// Target as the web fallback.
+type Resolution struct {
+	Target  string
+	Variant int
+	AppURL  string
+}
+
+func (s *URLService) normalizeDestinations(ctx context.Context, destinations []Destination) error {
+	if len(destinations) > MaxDestinations {
+		return fmt.Errorf("at most %d destinations are allowed", MaxDestinations)
+	}
+
+	for i := range destinations {
+		target, err := s.normalizeDestination(ctx, destinations[i].URL)
+		if err != nil {
+			return fmt.Errorf("invalid destination %d: %w", i+1, err)
+		}
+		if destinations[i].Weight <= 0 || destinations[i].Weight > MaxWeight {
+			return fmt.Errorf("invalid destination %d: weight must be between 1 and %d", i+1, MaxWeight)
+		}
+		destinations[i].URL = target
+	}
+
+	return nil
+}
+
+// chooseVariant keeps a visitor on the variant they were assigned before,
+// and otherwise draws one at random by weight.
+func (l *Link) chooseVariant(assigned int) int {
+	if assigned >= 0 && assigned < len(l.Destinations) {
+		return assigned
+	}
+
+	total := 0
+	for _, d := range l.Destinations {
+		total += d.Weight
+	}
+
+	// Links saved before weights were capped may not add up.
+	if total <= 0 {
+		return 0
+	}
+
+	n := rand.IntN(total)
+	for i, d := range l.Destinations {
+		if n < d.Weight {
+			return i
+		}
+		n -= d.Weight
+	}
+
+	return len(l.Destinations) - 1
+}
+
+func (s *URLService) initVariantClicks(ctx context.Context, shortID string, destinations []Destination, ttl time.Duration) error {
+	values := make(map[string]interface{}, len(destinations))
+	for i := range destinations {
+		values[strconv.Itoa(i)] = 0
+	}
+
+	return s.cache.HSet(ctx, variantClicksKey(shortID), values, ttl)
+}
+
+func (s *URLService) getVariantStats(ctx context.Context, link *Link) ([]VariantStats, error) {
+	counts, err := s.cache.HGetAll(ctx, variantClicksKey(link.ShortID))
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]VariantStats, len(link.Destinations))
+	for i, d := range link.Destinations {
+		clicks, _ := strconv.ParseInt(counts[strconv.Itoa(i)], 10, 64)
+		stats[i] = VariantStats{
+			Variant: i,
+			URL:     d.URL,
+			Weight:  d.Weight,
+			Clicks:  clicks,
+		}
+	}
+
+	return stats, nil
+}
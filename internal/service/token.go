@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/william1nguyen/shortygo/internal/config"
+	"github.com/william1nguyen/shortygo/pkg/utils"
+)
+
+const (
+	// tokenKeyIDPrefix starts the principal key ID of callers
+	// authenticated by a bearer token, followed by the token subject, so
+	// each subject gets its own rate limits.
+	tokenKeyIDPrefix = "jwt:"
+
+	// minJWKSReload limits how often an unknown key ID triggers a reload,
+	// so tokens with made-up key IDs cannot hammer the identity provider.
+	minJWKSReload = time.Minute
+
+	maxJWKSSize = 1 << 20
+	tokenLeeway = 30 * time.Second
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid bearer token")
+	ErrKeySetFailed = errors.New("failed to load key set")
+)
+
+var tokenSigningMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+// TokenVerifier authenticates JWT bearer tokens issued by an identity
+// provider against its published key set.
+type TokenVerifier struct {
+	cfg    config.AuthConfig
+	client *http.Client
+	parser *jwt.Parser
+
+	mu       sync.RWMutex
+	keys     map[string]crypto.PublicKey
+	loadedAt time.Time
+}
+
+// NewTokenVerifier loads the configured key set, failing if it cannot be
+// read so that a misconfigured deployment does not start.
+func NewTokenVerifier(ctx context.Context, cfg config.AuthConfig) (*TokenVerifier, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(tokenSigningMethods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(tokenLeeway),
+	}
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+
+	v := &TokenVerifier{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		parser: jwt.NewParser(options...),
+	}
+
+	if err := v.reload(ctx); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// Verify checks the token signature and claims and returns the principal
+// it stands for. Scopes the service does not know are dropped.
+func (v *TokenVerifier) Verify(ctx context.Context, raw string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.key(ctx, kid)
+	})
+	if errors.Is(err, ErrKeySetFailed) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	owner, _ := claims[v.cfg.OwnerClaim].(string)
+	if owner == "" {
+		return nil, fmt.Errorf("%w: missing %s claim", ErrInvalidToken, v.cfg.OwnerClaim)
+	}
+
+	var scopes []string
+	for _, scope := range claimStrings(claims[v.cfg.ScopesClaim]) {
+		if slices.Contains(Scopes, scope) && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		subject = owner
+	}
+
+	return &Principal{KeyID: tokenKeyIDPrefix + subject, Owner: owner, Scopes: scopes}, nil
+}
+
+// key finds the verification key for kid, reloading the key set when it is
+// stale or the identity provider has rotated in a key we have not seen.
+func (v *TokenVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.RLock()
+	key, ok := v.lookup(kid)
+	age := time.Since(v.loadedAt)
+	v.mu.RUnlock()
+
+	if (!ok && age >= minJWKSReload) || age >= v.cfg.JWKSRefresh {
+		if err := v.reload(ctx); err != nil {
+			if ok {
+				return key, nil
+			}
+			return nil, err
+		}
+
+		v.mu.RLock()
+		key, ok = v.lookup(kid)
+		v.mu.RUnlock()
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+
+	return key, nil
+}
+
+// lookup falls back to the only key of the set for tokens without a key
+// ID. Callers must hold mu.
+func (v *TokenVerifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if key, ok := v.keys[kid]; ok {
+		return key, true
+	}
+
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+
+	return nil, false
+}
+
+func (v *TokenVerifier) reload(ctx context.Context) error {
+	data, err := v.fetchKeySet(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrKeySetFailed, err)
+	}
+
+	keys, err := utils.ParseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrKeySetFailed, err)
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.loadedAt = time.Now()
+	v.mu.Unlock()
+
+	return nil
+}
+
+func (v *TokenVerifier) fetchKeySet(ctx context.Context) ([]byte, error) {
+	source := v.cfg.JWKS
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(source)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s answered %s", source, resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+}
+
+// claimStrings reads a claim that is either a space separated string, as
+// in the OAuth scope claim, or a list of strings.
+func claimStrings(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/william1nguyen/shortygo/internal/config"
+)
+
+// writeJWKS publishes the public half of key under kid in a local key set
+// file.
+func writeJWKS(t *testing.T, kid string, key *ecdsa.PrivateKey) string {
+	t.Helper()
+
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	size := (key.Curve.Params().BitSize + 7) / 8
+	set := map[string]any{
+		"keys": []map[string]string{{
+			"kty": "EC",
+			"kid": kid,
+			"crv": "P-256",
+			"x":   encode(key.X.FillBytes(make([]byte, size))),
+			"y":   encode(key.Y.FillBytes(make([]byte, size))),
+		}},
+	}
+
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTokenVerifierVerify(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier, err := NewTokenVerifier(context.Background(), config.AuthConfig{
+		JWKS:        writeJWKS(t, "k1", key),
+		JWKSRefresh: time.Hour,
+		Issuer:      "https://idp.example",
+		Audience:    "shortygo",
+		OwnerClaim:  "sub",
+		ScopesClaim: "scope",
+	})
+	if err != nil {
+		t.Fatalf("NewTokenVerifier: %v", err)
+	}
+
+	now := time.Now()
+	claims := func(mutate func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss":   "https://idp.example",
+			"aud":   "shortygo",
+			"sub":   "alice",
+			"scope": "links:write links:read unknown:scope",
+			"exp":   now.Add(time.Hour).Unix(),
+		}
+		if mutate != nil {
+			mutate(c)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		kid     string
+		claims  jwt.MapClaims
+		wantErr bool
+	}{
+		{name: "valid", kid: "k1", claims: claims(nil)},
+		{
+			name:    "expired",
+			kid:     "k1",
+			claims:  claims(func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Hour).Unix() }),
+			wantErr: true,
+		},
+		{name: "unknown key ID", kid: "k2", claims: claims(nil), wantErr: true},
+		{
+			name:    "wrong audience",
+			kid:     "k1",
+			claims:  claims(func(c jwt.MapClaims) { c["aud"] = "another-service" }),
+			wantErr: true,
+		},
+		{
+			name:    "wrong issuer",
+			kid:     "k1",
+			claims:  claims(func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }),
+			wantErr: true,
+		},
+		{
+			name:    "missing owner",
+			kid:     "k1",
+			claims:  claims(func(c jwt.MapClaims) { delete(c, "sub") }),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := jwt.NewWithClaims(jwt.SigningMethodES256, tt.claims)
+			token.Header["kid"] = tt.kid
+			raw, err := token.SignedString(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			principal, err := verifier.Verify(context.Background(), raw)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("Verify() error = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+
+			if principal.Owner != "alice" {
+				t.Errorf("Owner = %q, want alice", principal.Owner)
+			}
+			if principal.KeyID != "jwt:alice" {
+				t.Errorf("KeyID = %q, want jwt:alice", principal.KeyID)
+			}
+			if !principal.HasScope(ScopeLinksWrite) || !principal.HasScope(ScopeLinksRead) || len(principal.Scopes) != 2 {
+				t.Errorf("Scopes = %v, want links:write and links:read", principal.Scopes)
+			}
+		})
+	}
+}
+
+func TestTokenVerifierRejectsOtherSigner(t *testing.T) {
+	published, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier, err := NewTokenVerifier(context.Background(), config.AuthConfig{
+		JWKS:        writeJWKS(t, "k1", published),
+		JWKSRefresh: time.Hour,
+		OwnerClaim:  "sub",
+		ScopesClaim: "scope",
+	})
+	if err != nil {
+		t.Fatalf("NewTokenVerifier: %v", err)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"sub": "mallory",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = "k1"
+	raw, err := token.SignedString(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := verifier.Verify(context.Background(), raw); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Verify() error = %v, want ErrInvalidToken", err)
+	}
+}
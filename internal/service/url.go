@@ -2,8 +2,10 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,12 +15,46 @@ import (
 )
 
 type URLService struct {
-	cache *cache.RedisCache
+	cfg    *config.Config
+	cache  *cache.RedisCache
+	policy *DestinationPolicy
 }
 
 type ShortenRequest struct {
 	URL string `json:"url" binding:"required"`
 	TTL int    `json:"ttl,omitempty"`
+	// Interstitial always shows the preview page before redirecting,
+	// for destinations visitors should check first.
+	Interstitial bool `json:"interstitial,omitempty"`
+	// Password, when set, must be entered before the link redirects.
+	Password string `json:"password,omitempty"`
+	// MaxClicks makes the link stop working after that many redirects.
+	MaxClicks int `json:"max_clicks,omitempty"`
+	// ActivateAt and ExpiresAt are unix timestamps bounding when the link
+	// redirects. ExpiresAt replaces TTL; without it, TTL counts from ActivateAt.
+	ActivateAt int64 `json:"activate_at,omitempty"`
+	ExpiresAt  int64 `json:"expires_at,omitempty"`
+	// FallbackURL is where visitors outside the activation window are sent.
+	FallbackURL string `json:"fallback_url,omitempty"`
+	// Rules send matching visitors somewhere other than URL.
+	Rules []RedirectRule `json:"rules,omitempty"`
+	// Destinations split visitors no rule matched by weight, in place of URL.
+	Destinations []Destination `json:"destinations,omitempty"`
+	// QueryParams are added to the target on redirect, e.g. UTM tags, on
+	// top of the configured and API key defaults. Parameters already on the
+	// target win.
+	QueryParams map[string]string `json:"query_params,omitempty"`
+	// ForwardQuery passes the query the visitor put on the short URL on to
+	// the target.
+	ForwardQuery bool `json:"forward_query,omitempty"`
+	// ForwardPath lets /{shortId}/rest redirect to the target with rest
+	// appended to its path.
+	ForwardPath bool `json:"forward_path,omitempty"`
+	// DeepLink offers mobile visitors an app before the web target.
+	DeepLink *DeepLink `json:"deep_link,omitempty"`
+	// SocialMeta is served as Open Graph and Twitter Card tags to link
+	// preview crawlers.
+	SocialMeta
 }
 
 type ShortenResponse struct {
@@ -27,13 +63,28 @@ type ShortenResponse struct {
 	OriginalURL string `json:"origin_url"`
 	ExpiresAt   int64  `json:"expires_at"`
 	CreatedAt   int64  `json:"created_at"`
+	ActivateAt  int64  `json:"activate_at,omitempty"`
+	MaxClicks   int    `json:"max_clicks,omitempty"`
 }
 
 type URLStats struct {
+	ShortID     string         `json:"short_id"`
+	OriginalURL string         `json:"origin_url"`
+	Owner       string         `json:"owner,omitempty"`
+	ExpiresAt   int64          `json:"expires_at"`
+	CreatedAt   int64          `json:"created_at"`
+	Clicks      int64          `json:"clicks"`
+	Variants    []VariantStats `json:"variants,omitempty"`
+}
+
+// LinkPreview is the public preview of a link.
+type LinkPreview struct {
 	ShortID     string `json:"short_id"`
-	OriginalURL string `json:"origin_url"`
+	Destination string `json:"destination"`
+	Title       string `json:"title,omitempty"`
 	ExpiresAt   int64  `json:"expires_at"`
 	CreatedAt   int64  `json:"created_at"`
+	Clicks      int64  `json:"clicks"`
 }
 
 const (
@@ -41,66 +92,283 @@ const (
 	MaxTTL     = 365 * 24 * time.Hour
 	MinTTL     = 1 * time.Minute
 	MaxRetres  = 3
+
+	// TombstoneTTL is how long we remember a link after it expired or was
+	// deleted, so lookups can answer 410 Gone instead of 404 Not Found.
+	TombstoneTTL = 30 * 24 * time.Hour
 )
 
-func NewURLService(cache *cache.RedisCache) *URLService {
-	return &URLService{cache: cache}
+var (
+	ErrURLNotFound = errors.New("URL not found")
+	ErrURLGone     = errors.New("URL expired or deleted")
+)
+
+// NewURLService creates the link service. A nil policy allows every
+// http(s) destination.
+func NewURLService(cfg *config.Config, cache *cache.RedisCache, policy *DestinationPolicy) *URLService {
+	return &URLService{cfg: cfg, cache: cache, policy: policy}
 }
 
-func (s *URLService) ShortenURL(ctx context.Context, req *ShortenRequest) (*ShortenResponse, error) {
-	normalizeURL, err := s.normalizeURL(req.URL)
+// ShortenURL creates a link owned by the principal, which may be nil for
+// links created outside an authenticated request.
+func (s *URLService) ShortenURL(ctx context.Context, req *ShortenRequest, principal *Principal) (*ShortenResponse, error) {
+	normalizeURL, err := s.normalizeDestination(ctx, req.URL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
 
-	if err := s.validateURL(normalizeURL); err != nil {
-		return nil, fmt.Errorf("invalid URL: %w", err)
+	if req.MaxClicks < 0 {
+		return nil, fmt.Errorf("max_clicks must not be negative")
 	}
 
-	ttl := s.determineTTL(req.TTL)
+	var fallbackURL string
+	if req.FallbackURL != "" {
+		fallbackURL, err = s.normalizeDestination(ctx, req.FallbackURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fallback URL: %w", err)
+		}
+	}
+
+	if err := s.normalizeRules(ctx, req.Rules); err != nil {
+		return nil, err
+	}
+
+	if err := s.normalizeDestinations(ctx, req.Destinations); err != nil {
+		return nil, err
+	}
+
+	if err := s.normalizeDeepLink(ctx, req.DeepLink, s.cfg.Links.AppSchemes); err != nil {
+		return nil, err
+	}
+
+	if err := s.normalizeSocialMeta(&req.SocialMeta); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	activateAt, expiresAt, err := s.determineWindow(req, now)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := expiresAt.Sub(now)
+	if fallbackURL != "" {
+		ttl += FallbackRetention
+	}
+
+	var passwordHash string
+	if req.Password != "" {
+		passwordHash, err = hashPassword(req.Password)
+		if err != nil {
+			return nil, fmt.Errorf("invalid password: %w", err)
+		}
+	}
 
 	shortID, err := s.generateUniqueShortID(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate short ID: %w", err)
 	}
 
-	if err := s.cache.Set(ctx, shortID, normalizeURL, ttl); err != nil {
+	link := &Link{
+		ShortID:      shortID,
+		OriginalURL:  normalizeURL,
+		CreatedAt:    now.Unix(),
+		ExpiresAt:    expiresAt.Unix(),
+		Interstitial: req.Interstitial,
+		PasswordHash: passwordHash,
+		MaxClicks:    req.MaxClicks,
+		FallbackURL:  fallbackURL,
+		Rules:        req.Rules,
+		Destinations: req.Destinations,
+		QueryParams:  mergeQueryParams(s.cfg.Links.DefaultQueryParams, principal.defaultQueryParams(), req.QueryParams),
+		ForwardQuery: req.ForwardQuery,
+		ForwardPath:  req.ForwardPath,
+		DeepLink:     req.DeepLink,
+		SocialMeta:   req.SocialMeta,
+	}
+
+	if activateAt.After(now) {
+		link.ActivateAt = activateAt.Unix()
+	}
+
+	if principal != nil {
+		link.Owner = principal.Owner
+		link.CreatedBy = principal.KeyID
+	}
+
+	// The counters are written before the link, so a visible link always
+	// has them.
+	if err := s.cache.Set(ctx, clicksKey(shortID), "0", ttl); err != nil {
 		return nil, fmt.Errorf("failed to store URL: %w", err)
 	}
 
-	now := time.Now()
-	expiresAt := now.Add(ttl)
+	if len(req.Destinations) > 0 {
+		if err := s.initVariantClicks(ctx, shortID, req.Destinations, ttl); err != nil {
+			return nil, fmt.Errorf("failed to store URL: %w", err)
+		}
+	}
+
+	if req.MaxClicks > 0 {
+		if err := s.cache.Set(ctx, remainingClicksKey(shortID), strconv.Itoa(req.MaxClicks), ttl); err != nil {
+			return nil, fmt.Errorf("failed to store URL: %w", err)
+		}
+	}
+
+	if err := s.cache.Set(ctx, tombstoneKey(shortID), "expired", ttl+TombstoneTTL); err != nil {
+		return nil, fmt.Errorf("failed to store URL: %w", err)
+	}
+
+	if err := s.saveLink(ctx, link, ttl); err != nil {
+		return nil, fmt.Errorf("failed to store URL: %w", err)
+	}
 
 	return &ShortenResponse{
-		ShortURL:    fmt.Sprintf("%s/%s", config.Load().BaseURL, shortID),
+		ShortURL:    s.shortURL(shortID),
 		ShortID:     shortID,
 		OriginalURL: normalizeURL,
 		ExpiresAt:   expiresAt.Unix(),
 		CreatedAt:   now.Unix(),
+		ActivateAt:  link.ActivateAt,
+		MaxClicks:   req.MaxClicks,
 	}, nil
 }
 
-func (s *URLService) GetOriginalURL(ctx context.Context, shortID string) (string, error) {
+// GetLink looks up a link without counting a visit.
+func (s *URLService) GetLink(ctx context.Context, shortID string) (*Link, error) {
 	if shortID == "" {
-		return "", fmt.Errorf("short ID cannot be empty")
+		return nil, fmt.Errorf("short ID cannot be empty")
 	}
 
-	if err := s.validateShortID(shortID); err != nil {
-		return "", fmt.Errorf("invalid short ID: %w", err)
+	return s.loadLink(ctx, shortID)
+}
+
+// GetManagedLink looks up a link the principal is allowed to manage.
+func (s *URLService) GetManagedLink(ctx context.Context, shortID string, principal *Principal) (*Link, error) {
+	link, err := s.GetLink(ctx, shortID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := principal.authorize(link); err != nil {
+		return nil, err
+	}
+
+	return link, nil
+}
+
+func (s *URLService) GetStats(ctx context.Context, shortID string, principal *Principal) (*URLStats, error) {
+	link, err := s.GetManagedLink(ctx, shortID, principal)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := s.linkStats(ctx, link)
+	if err != nil {
+		return nil, err
 	}
 
-	url, err := s.cache.Get(ctx, shortID)
+	stats.Owner = link.Owner
+	return stats, nil
+}
+
+// PreviewLink is what anyone may see of a link before following it: where
+// it takes the visitor and how often it was followed, but not the
+// destinations of its other variants.
+func (s *URLService) PreviewLink(ctx context.Context, link *Link, visitor *Visitor) (*LinkPreview, error) {
+	clicks, err := s.getCounter(ctx, clicksKey(link.ShortID))
 	if err != nil {
-		return "", fmt.Errorf("URL not found or expired")
+		return nil, fmt.Errorf("failed to read clicks: %w", err)
+	}
+
+	return &LinkPreview{
+		ShortID:     link.ShortID,
+		Destination: s.ResolveTarget(link, visitor).Target,
+		Title:       link.Title,
+		ExpiresAt:   link.ExpiresAt,
+		CreatedAt:   link.CreatedAt,
+		Clicks:      clicks,
+	}, nil
+}
+
+func (s *URLService) linkStats(ctx context.Context, link *Link) (*URLStats, error) {
+	clicks, err := s.getCounter(ctx, clicksKey(link.ShortID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read clicks: %w", err)
+	}
+
+	stats := &URLStats{
+		ShortID:     link.ShortID,
+		OriginalURL: link.OriginalURL,
+		ExpiresAt:   link.ExpiresAt,
+		CreatedAt:   link.CreatedAt,
+		Clicks:      clicks,
+	}
+
+	if len(link.Destinations) > 0 {
+		stats.Variants, err = s.getVariantStats(ctx, link)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read variant clicks: %w", err)
+		}
+	}
+
+	return stats, nil
+}
+
+func (s *URLService) DeleteURL(ctx context.Context, shortID string, principal *Principal) error {
+	if err := s.validateShortID(shortID); err != nil {
+		return fmt.Errorf("invalid short ID: %w: %w", err, ErrURLNotFound)
+	}
+
+	if _, err := s.GetManagedLink(ctx, shortID, principal); err != nil {
+		return err
+	}
+
+	if err := s.cache.Delete(ctx, shortID); err != nil {
+		return fmt.Errorf("failed to delete URL: %w", err)
+	}
+
+	if err := s.cache.Delete(ctx, clicksKey(shortID)); err != nil {
+		return fmt.Errorf("failed to delete URL: %w", err)
+	}
+
+	if err := s.cache.Delete(ctx, remainingClicksKey(shortID)); err != nil {
+		return fmt.Errorf("failed to delete URL: %w", err)
 	}
 
-	return url, nil
+	if err := s.cache.Delete(ctx, variantClicksKey(shortID)); err != nil {
+		return fmt.Errorf("failed to delete URL: %w", err)
+	}
+
+	if err := s.cache.Set(ctx, tombstoneKey(shortID), "deleted", TombstoneTTL); err != nil {
+		return fmt.Errorf("failed to store tombstone: %w", err)
+	}
+
+	return nil
 }
 
 func (s *URLService) GetCacheMetrics() *cache.CacheMetrics {
 	return s.cache.GetMetrics()
 }
 
+// lookupError turns a failed link lookup into ErrURLGone when a tombstone
+// shows the link existed, or ErrURLNotFound when it never did.
+func (s *URLService) lookupError(ctx context.Context, shortID string, err error) error {
+	if !errors.Is(err, cache.ErrKeyNotFound) {
+		return fmt.Errorf("failed to look up URL: %w", err)
+	}
+
+	gone, err := s.cache.Exists(ctx, tombstoneKey(shortID))
+	if err != nil {
+		return fmt.Errorf("failed to look up URL: %w", err)
+	}
+	if gone {
+		return ErrURLGone
+	}
+
+	return ErrURLNotFound
+}
+
 func (s *URLService) normalizeURL(URL string) (string, error) {
 	if URL == "" {
 		return "", fmt.Errorf("URL cannot be empty")
@@ -132,7 +400,7 @@ func (s *URLService) validateURL(URL string) error {
 		return fmt.Errorf("URL must use HTTP or HTTPS protocol")
 	}
 
-	return nil
+	return s.policy.Check(parsedURL)
 }
 
 func (s *URLService) determineTTL(requestTTL int) time.Duration {
@@ -160,7 +428,12 @@ func (s *URLService) generateUniqueShortID(ctx context.Context) (string, error)
 			continue
 		}
 
-		if !exists {
+		gone, err := s.cache.Exists(ctx, tombstoneKey(shortID))
+		if err != nil {
+			continue
+		}
+
+		if !exists && !gone {
 			return shortID, nil
 		}
 	}
@@ -177,5 +450,11 @@ func (s *URLService) validateShortID(shortID string) error {
 		return fmt.Errorf("short ID too long")
 	}
 
+	// Links share the keyspace with internal records such as
+	// "apikey:{id}", which IDs outside the generated alphabet could name.
+	if strings.Trim(shortID, shortid.DefaultABC) != "" || shortID == apiKeySetKey {
+		return fmt.Errorf("short ID has an invalid format")
+	}
+
 	return nil
 }
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// FallbackRetention keeps links with a fallback URL around after they
+// expire, so late visitors are sent to the fallback instead of a 410.
+const FallbackRetention = 30 * 24 * time.Hour
+
+var ErrURLNotActive = errors.New("URL not active yet")
+
+// determineWindow works out when a new link starts and stops redirecting.
+func (s *URLService) determineWindow(req *ShortenRequest, now time.Time) (time.Time, time.Time, error) {
+	activateAt := now
+	if req.ActivateAt > 0 {
+		activateAt = time.Unix(req.ActivateAt, 0)
+		if activateAt.Sub(now) > MaxTTL {
+			return time.Time{}, time.Time{}, fmt.Errorf("activate_at must be within %v from now", MaxTTL)
+		}
+		if activateAt.Before(now) {
+			activateAt = now
+		}
+	}
+
+	if req.ExpiresAt <= 0 {
+		return activateAt, activateAt.Add(s.determineTTL(req.TTL)), nil
+	}
+
+	if req.TTL > 0 {
+		return time.Time{}, time.Time{}, fmt.Errorf("ttl and expires_at cannot both be set")
+	}
+
+	expiresAt := time.Unix(req.ExpiresAt, 0)
+	if !expiresAt.After(activateAt) {
+		return time.Time{}, time.Time{}, fmt.Errorf("expires_at must be after activate_at")
+	}
+
+	if lifetime := expiresAt.Sub(now); lifetime < MinTTL || lifetime > MaxTTL {
+		return time.Time{}, time.Time{}, fmt.Errorf("expires_at must be between %v and %v from now", MinTTL, MaxTTL)
+	}
+
+	return activateAt, expiresAt, nil
+}
+
+// CheckWindow reports whether the link redirects at the given time,
+// returning ErrURLNotActive before activation and ErrURLGone after expiry.
+func (l *Link) CheckWindow(now time.Time) error {
+	if l.ActivateAt > 0 && now.Unix() < l.ActivateAt {
+		return ErrURLNotActive
+	}
+
+	if l.ExpiresAt > 0 && now.Unix() >= l.ExpiresAt {
+		return ErrURLGone
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// QROptions controls how a QR code is drawn. Size is the image width and
+// height in pixels, Margin the quiet zone in modules.
+type QROptions struct {
+	Size       int
+	Level      string
+	Margin     int
+	Foreground color.RGBA
+	Background color.RGBA
+}
+
+var qrLevels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// ParseHexColor parses "rrggbb" or "rgb", with or without a leading "#".
+func ParseHexColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+
+	return color.RGBA{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: 0xff}, nil
+}
+
+// qrModules encodes content and returns its modules surrounded by the
+// requested quiet zone; true is a dark module.
+func qrModules(content string, opts QROptions) ([][]bool, error) {
+	level, ok := qrLevels[strings.ToUpper(opts.Level)]
+	if !ok {
+		return nil, fmt.Errorf("invalid error correction level %q", opts.Level)
+	}
+
+	code, err := qrcode.New(content, level)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	code.DisableBorder = true
+
+	bitmap := code.Bitmap()
+	total := len(bitmap) + 2*opts.Margin
+
+	modules := make([][]bool, total)
+	for y := range modules {
+		modules[y] = make([]bool, total)
+	}
+	for y, row := range bitmap {
+		copy(modules[y+opts.Margin][opts.Margin:], row)
+	}
+
+	return modules, nil
+}
+
+// EncodeQRPNG draws content as a QR code PNG of exactly opts.Size pixels.
+func EncodeQRPNG(content string, opts QROptions) ([]byte, error) {
+	modules, err := qrModules(content, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	palette := color.Palette{opts.Background, opts.Foreground}
+	img := image.NewPaletted(image.Rect(0, 0, opts.Size, opts.Size), palette)
+
+	total := len(modules)
+	for y := 0; y < opts.Size; y++ {
+		row := modules[y*total/opts.Size]
+		for x := 0; x < opts.Size; x++ {
+			if row[x*total/opts.Size] {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// EncodeQRSVG draws content as a QR code SVG scaled to opts.Size pixels.
+func EncodeQRSVG(content string, opts QROptions) ([]byte, error) {
+	modules, err := qrModules(content, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	total := len(modules)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, opts.Size, total, total)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="%s"/>`, total, total, hexColor(opts.Background))
+	fmt.Fprintf(&buf, `<path fill="%s" d="`, hexColor(opts.Foreground))
+	for y, row := range modules {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+
+	return buf.Bytes(), nil
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,84 @@
+package utils
+
+import "strings"
+
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+
+	OSIOS     = "ios"
+	OSAndroid = "android"
+	OSWindows = "windows"
+	OSMacOS   = "macos"
+	OSLinux   = "linux"
+	OSOther   = "other"
+)
+
+var botMarkers = []string{"bot", "crawler", "spider", "slurp", "facebookexternalhit", "embedly", "preview"}
+
+// DetectOS makes a best-effort guess of the operating system from a
+// User-Agent header.
+func DetectOS(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	switch {
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return OSIOS
+	case strings.Contains(ua, "android"):
+		return OSAndroid
+	case strings.Contains(ua, "windows"):
+		return OSWindows
+	case strings.Contains(ua, "mac os x"), strings.Contains(ua, "macintosh"):
+		return OSMacOS
+	case strings.Contains(ua, "linux"), strings.Contains(ua, "x11"):
+		return OSLinux
+	default:
+		return OSOther
+	}
+}
+
+// DetectDevice makes a best-effort guess of the device class from a
+// User-Agent header.
+func DetectDevice(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	for _, marker := range botMarkers {
+		if strings.Contains(ua, marker) {
+			return DeviceBot
+		}
+	}
+
+	switch {
+	case strings.Contains(ua, "ipad"), strings.Contains(ua, "tablet"),
+		strings.Contains(ua, "android") && !strings.Contains(ua, "mobile"):
+		return DeviceTablet
+	case strings.Contains(ua, "mobi"), strings.Contains(ua, "iphone"), strings.Contains(ua, "ipod"):
+		return DeviceMobile
+	default:
+		return DeviceDesktop
+	}
+}
+
+// linkPreviewCrawlers are the user agents chat apps and social networks use
+// to fetch link previews.
+var linkPreviewCrawlers = []string{
+	"facebookexternalhit", "facebot", "twitterbot", "slackbot", "slack-imgproxy",
+	"linkedinbot", "discordbot", "telegrambot", "whatsapp", "skypeuripreview",
+	"pinterest", "redditbot", "embedly", "vkshare", "applebot", "mastodon",
+	"iframely", "googlebot", "bingbot",
+}
+
+// IsLinkPreviewCrawler reports whether the user agent belongs to a known
+// link preview crawler.
+func IsLinkPreviewCrawler(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+
+	for _, crawler := range linkPreviewCrawlers {
+		if strings.Contains(ua, crawler) {
+			return true
+		}
+	}
+	return false
+}